@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// accountMsg is the account object as returned by the server (RFC 8555, section 7.1.2)
+type accountMsg struct {
+	Status                 string          `json:"status"`
+	Contact                []string        `json:"contact,omitempty"`
+	TermsOfServiceAgreed   bool            `json:"termsOfServiceAgreed,omitempty"`
+	ExternalAccountBinding json.RawMessage `json:"externalAccountBinding,omitempty"`
+	Orders                 string          `json:"orders,omitempty"`
+}
+
+func (acme *acmeClient) postToAccount(ctx context.Context, logger *logrus.Entry, payload interface{}) (*accountMsg, error) {
+	if acme.accountURL == "" {
+		logger.Error("No account URL saved. Create account first.")
+		return nil, errors.New("Missing account URL - can't set kid")
+	}
+
+	headers := map[string]interface{}{
+		"kid": acme.accountURL,
+	}
+
+	resp, err := acme.doJosePostRequest(ctx, acme.accountURL, headers, payload)
+	if err != nil {
+		logger.Error("Error posting to account: ", err)
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error("Error reading response body: ", err)
+		return nil, err
+	}
+
+	if err := checkResponse(resp, body, 200); err != nil {
+		logger.WithError(err).Error("Error posting to account")
+		return nil, err
+	}
+
+	var account accountMsg
+	if err := json.Unmarshal(body, &account); err != nil {
+		logger.WithError(err).Error("Error unmarshalling account response")
+		return nil, err
+	}
+	return &account, nil
+}
+
+// fetchAccount retrieves the account object with a POST-as-GET request
+func (acme *acmeClient) fetchAccount(ctx context.Context) (*accountMsg, error) {
+	logger := acme.logger.WithField("method", "fetchAccount")
+	return acme.postToAccount(ctx, logger, nil)
+}
+
+// updateAccount replaces the contacts of the account (RFC 8555, section 7.3.2)
+func (acme *acmeClient) updateAccount(ctx context.Context, contacts []string) (*accountMsg, error) {
+	logger := acme.logger.WithField("method", "updateAccount")
+
+	// an empty list removes all contacts (--clear-contacts), nil would be dropped by the server
+	if contacts == nil {
+		contacts = []string{}
+	}
+	payload := map[string]interface{}{
+		"contact": contacts,
+	}
+
+	account, err := acme.postToAccount(ctx, logger, payload)
+	if err != nil {
+		return nil, err
+	}
+	acme.contacts = account.Contact
+	return account, acme.saveAccount()
+}
+
+// deactivateAccount deactivates the account for good (RFC 8555, section 7.3.6)
+func (acme *acmeClient) deactivateAccount(ctx context.Context) (*accountMsg, error) {
+	logger := acme.logger.WithField("method", "deactivateAccount")
+
+	payload := map[string]interface{}{
+		"status": "deactivated",
+	}
+
+	account, err := acme.postToAccount(ctx, logger, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// a deactivated account can't be used again, don't offer it to the next run
+	acme.savedAccount = false
+	if acme.state != nil {
+		if err := acme.state.DeactivateAccount(acme.accountURL); err != nil {
+			logger.WithError(err).Error("Error saving deactivated account")
+			return nil, err
+		}
+	}
+	return account, nil
+}
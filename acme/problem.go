@@ -0,0 +1,179 @@
+// Package acme holds the protocol types shared by the ACME client, starting
+// with problem documents (RFC 8555, section 6.7).
+package acme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	ACCOUNTDOESNOTEXIST     = "urn:ietf:params:acme:error:accountDoesNotExist"
+	ALREADYREVOKED          = "urn:ietf:params:acme:error:alreadyRevoked"
+	BADCSR                  = "urn:ietf:params:acme:error:badCSR"
+	BADNONCE                = "urn:ietf:params:acme:error:badNonce"
+	BADPUBLICKEY            = "urn:ietf:params:acme:error:badPublicKey"
+	BADREVOCATIONREASON     = "urn:ietf:params:acme:error:badRevocationReason"
+	BADSIGNATUREALGORITHM   = "urn:ietf:params:acme:error:badSignatureAlgorithm"
+	CAA                     = "urn:ietf:params:acme:error:caa"
+	COMPOUND                = "urn:ietf:params:acme:error:compound"
+	CONNECTION              = "urn:ietf:params:acme:error:connection"
+	DNS                     = "urn:ietf:params:acme:error:dns"
+	EXTERNALACCOUNTREQUIRED = "urn:ietf:params:acme:error:externalAccountRequired"
+	INCORRECTRESPONSE       = "urn:ietf:params:acme:error:incorrectResponse"
+	INVALIDCONTACT          = "urn:ietf:params:acme:error:invalidContact"
+	MALFORMED               = "urn:ietf:params:acme:error:malformed"
+	ORDERNOTREADY           = "urn:ietf:params:acme:error:orderNotReady"
+	RATELIMITED             = "urn:ietf:params:acme:error:rateLimited"
+	REJECTEDIDENTIFIER      = "urn:ietf:params:acme:error:rejectedIdentifier"
+	SERVERINTERNAL          = "urn:ietf:params:acme:error:serverInternal"
+	TLS                     = "urn:ietf:params:acme:error:tls"
+	UNAUTHORIZED            = "urn:ietf:params:acme:error:unauthorized"
+	UNSUPPORTEDCONTACT      = "urn:ietf:params:acme:error:unsupportedContact"
+	UNSUPPORTEDIDENTIFIER   = "urn:ietf:params:acme:error:unsupportedIdentifier"
+	USERACTIONREQUIRED      = "urn:ietf:params:acme:error:userActionRequired"
+)
+
+// Identifier is the identifier a subproblem refers to
+type Identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Problem is a problem document (RFC 7807) returned by the server. It is used as error throughout the client.
+type Problem struct {
+	Type        string      `json:"type"`
+	Detail      string      `json:"detail,omitempty"`
+	Status      int         `json:"status,omitempty"`
+	Instance    string      `json:"instance,omitempty"`
+	Identifier  *Identifier `json:"identifier,omitempty"`
+	Subproblems []Problem   `json:"subproblems,omitempty"`
+
+	// RetryAfter is the raw Retry-After header of the response, if any
+	RetryAfter string `json:"-"`
+}
+
+func (problem *Problem) Error() string {
+	var b strings.Builder
+	if problem.Status != 0 {
+		fmt.Fprintf(&b, "%d ", problem.Status)
+	}
+	b.WriteString(strings.TrimPrefix(problem.Type, "urn:ietf:params:acme:error:"))
+	if problem.Identifier != nil {
+		fmt.Fprintf(&b, " (%s %s)", problem.Identifier.Type, problem.Identifier.Value)
+	}
+	if problem.Detail != "" {
+		b.WriteString(": " + problem.Detail)
+	} else {
+		b.WriteString(": " + Description(problem.Type))
+	}
+	for _, sub := range problem.Subproblems {
+		b.WriteString("; " + sub.Error())
+	}
+	return b.String()
+}
+
+// Is makes errors.Is(err, &Problem{Type: ...}) match problems of the same type,
+// including compound problems with a subproblem of that type
+func (problem *Problem) Is(target error) bool {
+	t, ok := target.(*Problem)
+	if !ok {
+		return false
+	}
+	if t.Type == problem.Type {
+		return true
+	}
+	for i := range problem.Subproblems {
+		if problem.Subproblems[i].Is(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasType reports whether err is a problem of the given type, or a compound problem with such a subproblem
+func HasType(err error, problemType string) bool {
+	return errors.Is(err, &Problem{Type: problemType})
+}
+
+// ParseProblem builds a Problem from an error response. Bodies that are not
+// problem documents still produce a Problem carrying the HTTP status.
+func ParseProblem(resp *http.Response, body []byte) *Problem {
+	problem := &Problem{}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" || mediaType == "application/json" {
+		if err := json.Unmarshal(body, problem); err != nil {
+			problem = &Problem{}
+		}
+	}
+
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+		problem.Detail = strings.TrimSpace(string(body))
+	}
+	if problem.Status == 0 {
+		problem.Status = resp.StatusCode
+	}
+	problem.RetryAfter = resp.Header.Get("Retry-After")
+	return problem
+}
+
+// Description returns a human readable description of a problem type (RFC 8555, section 6.7)
+func Description(problemType string) string {
+	switch problemType {
+	case ACCOUNTDOESNOTEXIST:
+		return "The request specified an account that does not exist"
+	case ALREADYREVOKED:
+		return "The request specified a certificate to be revoked that has already been revoked"
+	case BADCSR:
+		return "The CSR is unacceptable (e.g., due to a short key)"
+	case BADNONCE:
+		return "The client sent an unacceptable anti-replay nonce"
+	case BADPUBLICKEY:
+		return "The JWS was signed by a public key the server does not support"
+	case BADREVOCATIONREASON:
+		return "The revocation reason provided is not allowed by the server"
+	case BADSIGNATUREALGORITHM:
+		return "The JWS was signed with an algorithm the server does not support"
+	case CAA:
+		return "Certification Authority Authorization (CAA) records forbid the CA from issuing a certificate"
+	case COMPOUND:
+		return "Specific error conditions are indicated in the `subproblems` array"
+	case CONNECTION:
+		return "The server could not connect to validation target"
+	case DNS:
+		return "There was a problem with a DNS query during identifier validation"
+	case EXTERNALACCOUNTREQUIRED:
+		return "The request must include a value for the 'externalAccountBinding' field"
+	case INCORRECTRESPONSE:
+		return "Response received didn't match the challenge's requirements"
+	case INVALIDCONTACT:
+		return "A contact URL for an account was invalid"
+	case MALFORMED:
+		return "The request message was malformed"
+	case ORDERNOTREADY:
+		return "The request attempted to finalize an order that is not ready to be finalized"
+	case RATELIMITED:
+		return "The request exceeds a rate limit"
+	case REJECTEDIDENTIFIER:
+		return "The server will not issue certificates for the identifier"
+	case SERVERINTERNAL:
+		return "The server experienced an internal error"
+	case TLS:
+		return "The server received a TLS error during validation"
+	case UNAUTHORIZED:
+		return "The client lacks sufficient authorization"
+	case UNSUPPORTEDCONTACT:
+		return "A contact URL for an account used an unsupported protocol scheme"
+	case UNSUPPORTEDIDENTIFIER:
+		return "An identifier is of an unsupported type"
+	case USERACTIONREQUIRED:
+		return "Visit the 'instance' URL and take actions specified there"
+	default:
+		return "Unknown error"
+	}
+}
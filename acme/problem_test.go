@@ -0,0 +1,154 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseProblem(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		status      int
+		retryAfter  string
+		body        string
+		want        Problem
+	}{
+		{
+			"problem document",
+			"application/problem+json",
+			http.StatusForbidden,
+			"",
+			`{"type": "urn:ietf:params:acme:error:unauthorized", "detail": "No such authorization", "status": 403, "instance": "https://example.com/docs/1"}`,
+			Problem{Type: UNAUTHORIZED, Detail: "No such authorization", Status: 403, Instance: "https://example.com/docs/1"},
+		},
+		{
+			// RFC 8555, section 6.7.1
+			"subproblems",
+			"application/problem+json; charset=utf-8",
+			http.StatusForbidden,
+			"",
+			`{"type": "urn:ietf:params:acme:error:malformed", "detail": "Some of the identifiers requested were rejected",
+			  "subproblems": [{"type": "urn:ietf:params:acme:error:malformed", "detail": "Invalid underscore in DNS name \"_example.org\"", "identifier": {"type": "dns", "value": "_example.org"}},
+			                  {"type": "urn:ietf:params:acme:error:rejectedIdentifier", "detail": "This CA will not issue for \"example.net\"", "identifier": {"type": "dns", "value": "example.net"}}]}`,
+			Problem{Type: MALFORMED, Detail: "Some of the identifiers requested were rejected", Status: 403, Subproblems: []Problem{
+				{Type: MALFORMED, Detail: `Invalid underscore in DNS name "_example.org"`, Identifier: &Identifier{Type: "dns", Value: "_example.org"}},
+				{Type: REJECTEDIDENTIFIER, Detail: `This CA will not issue for "example.net"`, Identifier: &Identifier{Type: "dns", Value: "example.net"}},
+			}},
+		},
+		{
+			"plain json",
+			"application/json",
+			http.StatusTooManyRequests,
+			"60",
+			`{"type": "urn:ietf:params:acme:error:rateLimited", "detail": "Slow down"}`,
+			Problem{Type: RATELIMITED, Detail: "Slow down", Status: 429, RetryAfter: "60"},
+		},
+		{
+			"wrong content type",
+			"text/html",
+			http.StatusBadGateway,
+			"",
+			`{"type": "urn:ietf:params:acme:error:serverInternal"}`,
+			Problem{Type: "about:blank", Detail: `{"type": "urn:ietf:params:acme:error:serverInternal"}`, Status: 502},
+		},
+		{
+			"not a problem document",
+			"application/problem+json",
+			http.StatusServiceUnavailable,
+			"",
+			"  upstream unavailable\n",
+			Problem{Type: "about:blank", Detail: "upstream unavailable", Status: 503},
+		},
+		{
+			"no content type",
+			"",
+			http.StatusNotFound,
+			"",
+			"",
+			Problem{Type: "about:blank", Status: 404},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: test.status, Header: http.Header{}}
+			if test.contentType != "" {
+				resp.Header.Set("Content-Type", test.contentType)
+			}
+			if test.retryAfter != "" {
+				resp.Header.Set("Retry-After", test.retryAfter)
+			}
+			if got := ParseProblem(resp, []byte(test.body)); !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("ParseProblem = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+func TestProblemError(t *testing.T) {
+	tests := []struct {
+		name    string
+		problem *Problem
+		want    string
+	}{
+		{"acme type", &Problem{Type: BADNONCE, Detail: "JWS has an invalid anti-replay nonce", Status: 400}, "400 badNonce: JWS has an invalid anti-replay nonce"},
+		{"no detail", &Problem{Type: ORDERNOTREADY}, "orderNotReady: " + Description(ORDERNOTREADY)},
+		{"other type", &Problem{Type: "about:blank", Detail: "Bad Gateway", Status: 502}, "502 about:blank: Bad Gateway"},
+		{
+			"identifier and subproblems",
+			&Problem{Type: COMPOUND, Detail: "Errors for several identifiers", Subproblems: []Problem{
+				{Type: CAA, Detail: "CAA forbids issuance", Identifier: &Identifier{Type: "dns", Value: "example.com"}},
+			}},
+			"compound: Errors for several identifiers; caa (dns example.com): CAA forbids issuance",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.problem.Error(); got != test.want {
+				t.Errorf("Error = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestProblemIs(t *testing.T) {
+	compound := &Problem{Type: COMPOUND, Subproblems: []Problem{
+		{Type: CAA},
+		{Type: MALFORMED, Subproblems: []Problem{{Type: DNS}}},
+	}}
+
+	tests := []struct {
+		name        string
+		err         error
+		problemType string
+		want        bool
+	}{
+		{"same type", &Problem{Type: BADNONCE}, BADNONCE, true},
+		{"other type", &Problem{Type: BADNONCE}, MALFORMED, false},
+		{"compound", compound, COMPOUND, true},
+		{"subproblem", compound, CAA, true},
+		{"nested subproblem", compound, DNS, true},
+		{"not a subproblem", compound, TLS, false},
+		{"wrapped", fmt.Errorf("Error creating order: %w", compound), CAA, true},
+		{"not a problem", errors.New("urn:ietf:params:acme:error:badNonce"), BADNONCE, false},
+		{"nil", nil, BADNONCE, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := errors.Is(test.err, &Problem{Type: test.problemType}); got != test.want {
+				t.Errorf("errors.Is = %t, want %t", got, test.want)
+			}
+			if got := HasType(test.err, test.problemType); got != test.want {
+				t.Errorf("HasType = %t, want %t", got, test.want)
+			}
+		})
+	}
+
+	var problem *Problem
+	if !errors.As(fmt.Errorf("wrapped: %w", compound), &problem) || problem != compound {
+		t.Error("errors.As does not find the problem")
+	}
+}
@@ -1,18 +1,21 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"strconv"
 	"time"
+
+	"github.com/komplexon3/acme-client/acme"
 )
 
 type authorization struct {
 	status           string
 	authorizationURL string
 	identifier       identifier
+	wildcard         bool
 	challenges       []challenge
 }
 
@@ -20,11 +23,12 @@ type authorizartionMsg struct {
 	Status     string      `json:"status"`
 	Challenges []challenge `json:"challenges"`
 	Identifier identifier  `json:"identifier"`
+	Wildcard   bool        `json:"wildcard,omitempty"`
 }
 
-func (acme *acmeClient) getAuthorization(authorizationURL string) (*authorization, int, error) {
+func (acme *acmeClient) getAuthorization(ctx context.Context, authorizationURL string) (*authorization, time.Duration, error) {
 	logger := acme.logger.WithField("method", "getAuthorization")
-	retryAfter := 0
+	var retryAfter time.Duration
 
 	if authorizationURL == "" {
 		logger.Error("No authorization URL")
@@ -42,16 +46,21 @@ func (acme *acmeClient) getAuthorization(authorizationURL string) (*authorizatio
 
 	// empty payload -> post-as-get
 
-	resp, err := acme.doJosePostRequest(authorizationURL, headers, nil)
-	body, err1 := io.ReadAll(resp.Body)
-	if err1 != nil {
-		logger.Error("Error reading response body: ", err)
+	resp, err := acme.doJosePostRequest(ctx, authorizationURL, headers, nil)
+	if err != nil {
+		logger.Error("Error getting authorization: ", err)
 		return nil, retryAfter, err
 	}
 
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		logger.WithField("ErrorDesc", getErrorDetails(string(body))).Error("Error getting authorization: ", err)
-		return nil, retryAfter, fmt.Errorf("Error getting authorization: %v", err)
+		logger.Error("Error reading response body: ", err)
+		return nil, retryAfter, err
+	}
+
+	if err := checkResponse(resp, body, 200); err != nil {
+		logger.WithError(err).Error("Error getting authorization")
+		return nil, retryAfter, err
 	}
 
 	var authorizationResponse authorizartionMsg
@@ -60,13 +69,9 @@ func (acme *acmeClient) getAuthorization(authorizationURL string) (*authorizatio
 		return nil, retryAfter, fmt.Errorf("Error unmarshalling authorization response: %v", err)
 	}
 
-	// check retry after
-	if retryAfterHeader := resp.Header.Get("Retry-After"); retryAfterHeader != "" {
-		retryAfter, err = strconv.Atoi(retryAfterHeader)
-		if err != nil {
-			logger.WithError(err).Info("Error parsing Retry-After header")
-			retryAfter = 0
-		}
+	// check retry after, either seconds or an HTTP-date
+	if retryAt := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); !retryAt.IsZero() {
+		retryAfter = time.Until(retryAt)
 	}
 
 	var auth authorization
@@ -74,6 +79,7 @@ func (acme *acmeClient) getAuthorization(authorizationURL string) (*authorizatio
 	auth.authorizationURL = authorizationURL
 	auth.challenges = authorizationResponse.Challenges
 	auth.identifier = authorizationResponse.Identifier
+	auth.wildcard = authorizationResponse.Wildcard
 
 	return &auth, retryAfter, nil
 }
@@ -115,13 +121,13 @@ func (acme *acmeClient) registerChallenge(auth *authorization, challengeType Cha
 	return nil, nil, fmt.Errorf("No challenge of type %s found", chalType)
 }
 
-func (acmeClient *acmeClient) pollAuthorization(auth *authorization, maxPoll int) error {
+func (acmeClient *acmeClient) pollAuthorization(ctx context.Context, auth *authorization, maxPoll int) error {
 	logger := acmeClient.logger.WithField("method", "pollAuthorization")
 
 	valid := false
 	i := 0
 	for !valid && i < maxPoll {
-		_auth, retryAfter, err := acmeClient.getAuthorization(auth.authorizationURL)
+		_auth, retryAfter, err := acmeClient.getAuthorization(ctx, auth.authorizationURL)
 		if err != nil {
 			logger.WithError(err).Error("Error getting authorization")
 			return err
@@ -132,11 +138,35 @@ func (acmeClient *acmeClient) pollAuthorization(auth *authorization, maxPoll int
 			valid = true
 			return nil
 		}
-		if retryAfter == 0 {
-			retryAfter = 1
+		if auth.status == "invalid" {
+			err := _auth.problem()
+			logger.WithError(err).Error("Authorization is invalid")
+			return err
+		}
+		if retryAfter <= 0 {
+			retryAfter = time.Second
+		}
+		if retryAfter > maxBackoff {
+			retryAfter = maxBackoff
+		}
+		if err := acmeClient.wait(ctx, retryAfter); err != nil {
+			return err
 		}
-		time.Sleep(time.Duration(retryAfter) * time.Second)
 		i++
 	}
 	return fmt.Errorf("Authorization not valid after %d polls", maxPoll)
 }
+
+// problem returns the error of the challenge that made the authorization invalid
+func (auth *authorization) problem() error {
+	for _, chal := range auth.challenges {
+		if chal.Error != nil {
+			problem := *chal.Error
+			if problem.Identifier == nil {
+				problem.Identifier = &acme.Identifier{Type: auth.identifier.Type, Value: auth.identifier.Value}
+			}
+			return &problem
+		}
+	}
+	return fmt.Errorf("Authorization for %s is invalid", auth.identifier.Value)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClient returns a client with a fresh P-256 account key that sends its
+// requests to server. Nonces are made up, the server is not asked for them.
+func testClient(t *testing.T, server *httptest.Server) *acmeClient {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acmeClient := &acmeClient{
+		logger:     discardLogger(),
+		privateKey: key,
+		accountURL: server.URL + "/account/1",
+		httpClient: server.Client(),
+	}
+	var nonces int32
+	acmeClient.nonces = newNoncePool(acmeClient.logger, func(context.Context) error {
+		acmeClient.nonces.Put(fmt.Sprint("nonce-", atomic.AddInt32(&nonces, 1)))
+		return nil
+	}, false)
+	return acmeClient
+}
+
+func TestGetAuthorizationRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		want       time.Duration
+	}{
+		{"none", "", 0},
+		{"seconds", "30", 30 * time.Second},
+		{"http date", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), time.Minute},
+		{"invalid", "soon", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.retryAfter != "" {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, `{"status": "pending", "identifier": {"type": "dns", "value": "example.com"}, "challenges": []}`)
+			}))
+			defer server.Close()
+
+			auth, retryAfter, err := testClient(t, server).getAuthorization(context.Background(), server.URL+"/authz/1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if auth.status != "pending" {
+				t.Errorf("status = %s, want pending", auth.status)
+			}
+			// an HTTP-date only has a precision of one second
+			if diff := retryAfter - test.want; diff < -time.Second || diff > time.Second {
+				t.Errorf("retry after = %s, want %s", retryAfter, test.want)
+			}
+		})
+	}
+}
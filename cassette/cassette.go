@@ -0,0 +1,254 @@
+// Package cassette records the exchanges with an ACME server and replays them
+// later, so that a full issuance can be run without a CA.
+//
+// Requests are matched on method, URL and the decoded JWS payload, not on the
+// signature, nonce or key. Keys are generated anew on every run, so parts of
+// the payload that depend on them are normalized first, see normalizePayload.
+// Requests with the same key are answered in recorded order and the last
+// response is repeated once they run out, which keeps polling loops working.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	Record = "record"
+	Replay = "replay"
+)
+
+// Cassette is the file the exchanges are kept in
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method  string          `json:"method"`
+	URL     string          `json:"url"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+	// BodyEncoding is base64 if the body is not valid UTF-8
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
+}
+
+// key identifies requests that are answered alike
+func (request *Request) key() string {
+	return request.Method + " " + request.URL + " " + string(request.Payload)
+}
+
+// Transport is an http.RoundTripper that either records the exchanges of its
+// base transport to a cassette or answers requests from a cassette
+type Transport struct {
+	base http.RoundTripper
+	path string
+	mode string
+
+	mu       sync.Mutex
+	cassette Cassette
+	// next is the index of the next unused interaction per request key
+	next map[string]int
+	// byKey holds the indices of the interactions per request key
+	byKey map[string][]int
+	// nonces counts the nonces handed out during replay
+	nonces int
+}
+
+// NewRecorder records the exchanges of base to path, an existing cassette is overwritten
+func NewRecorder(base http.RoundTripper, path string) (*Transport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	transport := &Transport{base: base, path: path, mode: Record}
+	if err := transport.save(); err != nil {
+		return nil, fmt.Errorf("Error creating cassette: %v", err)
+	}
+	return transport, nil
+}
+
+// NewReplayer answers requests from the cassette at path without any network access
+func NewReplayer(path string) (*Transport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading cassette: %v", err)
+	}
+	transport := &Transport{path: path, mode: Replay}
+	if err := json.Unmarshal(data, &transport.cassette); err != nil {
+		return nil, fmt.Errorf("Error parsing cassette %s: %v", path, err)
+	}
+
+	transport.next = make(map[string]int)
+	transport.byKey = make(map[string][]int)
+	for i := range transport.cassette.Interactions {
+		request := &transport.cassette.Interactions[i].Request
+		// the cassette is indented, payloads are compared in compact form
+		if len(request.Payload) > 0 {
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, request.Payload); err != nil {
+				return nil, fmt.Errorf("Error parsing cassette %s: %v", path, err)
+			}
+			request.Payload = compact.Bytes()
+		}
+		key := request.key()
+		transport.byKey[key] = append(transport.byKey[key], i)
+	}
+	return transport, nil
+}
+
+// Replaying reports whether requests are answered from the cassette
+func (transport *Transport) Replaying() bool {
+	return transport.mode == Replay
+}
+
+func (transport *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	request := Request{
+		Method: req.Method,
+		URL:    req.URL.String(),
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		request.Payload, err = normalizeBody(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if transport.mode == Replay {
+		return transport.replay(req, &request)
+	}
+	return transport.record(req, &request)
+}
+
+func (transport *Transport) record(req *http.Request, request *Request) (*http.Response, error) {
+	resp, err := transport.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	response := Response{
+		Status:  resp.StatusCode,
+		Headers: resp.Header.Clone(),
+	}
+	response.Headers.Del("Date")
+	if utf8.Valid(body) {
+		response.Body = string(body)
+	} else {
+		response.Body = base64.StdEncoding.EncodeToString(body)
+		response.BodyEncoding = "base64"
+	}
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	transport.cassette.Interactions = append(transport.cassette.Interactions, Interaction{Request: *request, Response: response})
+	// the cassette is written after every exchange, so that it is complete even if the client exits early
+	if err := transport.save(); err != nil {
+		return nil, fmt.Errorf("Error writing cassette: %v", err)
+	}
+	return resp, nil
+}
+
+func (transport *Transport) replay(req *http.Request, request *Request) (*http.Response, error) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	key := request.key()
+	indices := transport.byKey[key]
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("No recorded interaction for %s %s with payload %s", request.Method, request.URL, request.Payload)
+	}
+
+	// repeat the last response once the recorded ones are used up, e.g. when polling more often
+	next := transport.next[key]
+	if next < len(indices)-1 {
+		transport.next[key] = next + 1
+	} else {
+		next = len(indices) - 1
+	}
+	recorded := transport.cassette.Interactions[indices[next]].Response
+
+	body := []byte(recorded.Body)
+	if recorded.BodyEncoding == "base64" {
+		var err error
+		body, err = base64.StdEncoding.DecodeString(recorded.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding recorded body: %v", err)
+		}
+	}
+
+	header := recorded.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	// there is no server to wait for, a recorded Retry-After would only slow the replay down
+	header.Del("Retry-After")
+	// nonces are consumed by the client, repeated responses must not hand out the same one twice
+	if header.Get("Replay-Nonce") != "" {
+		transport.nonces++
+		header.Set("Replay-Nonce", fmt.Sprintf("replay-nonce-%d", transport.nonces))
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (transport *Transport) save() error {
+	data, err := json.MarshalIndent(&transport.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(transport.path), ".cassette-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), transport.path)
+}
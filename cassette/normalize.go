@@ -0,0 +1,89 @@
+package cassette
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+
+	"github.com/komplexon3/acme-client/jose"
+)
+
+// normalizeBody turns a JWS request body into the part that identifies the
+// request, its normalized payload. Other bodies are kept as they are.
+func normalizeBody(body []byte) (json.RawMessage, error) {
+	jws, err := jose.Parse(body)
+	if err != nil {
+		return json.Marshal(string(body))
+	}
+	if jws.IsPostAsGet() {
+		return nil, nil
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jws.Payload, &payload); err != nil {
+		return json.Marshal(string(jws.Payload))
+	}
+	normalizePayload(payload)
+	// encoding/json sorts map keys, so equal payloads encode equally
+	return json.Marshal(payload)
+}
+
+// normalizePayload replaces everything that depends on freshly generated keys:
+//
+//	csr                     the names it requests
+//	externalAccountBinding  the url and kid of the binding, its payload is the account key
+//	keyChange               the account, oldKey and the new key change on every run
+func normalizePayload(payload map[string]interface{}) {
+	if csr, ok := payload["csr"].(string); ok {
+		payload["csr"] = normalizeCSR(csr)
+	}
+	if binding, ok := payload["externalAccountBinding"].(map[string]interface{}); ok {
+		payload["externalAccountBinding"] = normalizeNestedJWS(binding)
+	}
+	if _, ok := payload["protected"]; ok {
+		if _, ok := payload["signature"]; ok {
+			for name, value := range normalizeNestedJWS(payload) {
+				payload[name] = value
+			}
+			delete(payload, "payload")
+			delete(payload, "signature")
+		}
+	}
+}
+
+// normalizeCSR reduces a CSR to the sorted names it asks for
+func normalizeCSR(encoded string) interface{} {
+	der, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return encoded
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return encoded
+	}
+	names := append([]string{}, csr.DNSNames...)
+	sort.Strings(names)
+	return map[string]interface{}{"dnsNames": names}
+}
+
+// normalizeNestedJWS keeps the url and kid of a JWS embedded in a payload
+func normalizeNestedJWS(nested map[string]interface{}) map[string]interface{} {
+	protected, _ := nested["protected"].(string)
+	data, err := base64.RawURLEncoding.DecodeString(protected)
+	if err != nil {
+		return map[string]interface{}{}
+	}
+	var header map[string]interface{}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return map[string]interface{}{}
+	}
+
+	normalized := map[string]interface{}{}
+	for _, name := range []string{"url", "kid"} {
+		if value, ok := header[name]; ok {
+			normalized[name] = value
+		}
+	}
+	return map[string]interface{}{"protected": normalized}
+}
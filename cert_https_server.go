@@ -1,6 +1,13 @@
 package main
 
 import (
+	"crypto"
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"os"
+
 	gin "github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	ginlogrus "github.com/toorop/gin-logrus"
@@ -10,6 +17,7 @@ type CertHttpsServer struct {
 	server          *gin.Engine
 	keyFile         string
 	certificateFile string
+	signer          crypto.Signer
 	port            string
 	logger          *logrus.Entry
 }
@@ -34,7 +42,52 @@ func InitCertServer(logger *logrus.Entry, port string, keyFile string, certifica
 
 }
 
+// SetSigner makes the server use a key held by an external signer instead of the key file
+func (c *CertHttpsServer) SetSigner(signer crypto.Signer) {
+	c.signer = signer
+}
+
 func (c *CertHttpsServer) Start() {
-	// start the server
-	c.server.RunTLS(":"+c.port, c.certificateFile, c.keyFile)
+	if c.signer == nil {
+		// start the server
+		c.server.RunTLS(":"+c.port, c.certificateFile, c.keyFile)
+		return
+	}
+
+	certificate, err := c.loadCertificate()
+	if err != nil {
+		c.logger.WithError(err).Error("Error loading certificate")
+		return
+	}
+	server := &http.Server{
+		Addr:      ":" + c.port,
+		Handler:   c.server,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{*certificate}},
+	}
+	if err := server.ListenAndServeTLS("", ""); err != nil {
+		c.logger.WithError(err).Error("Error running server")
+	}
+}
+
+func (c *CertHttpsServer) loadCertificate() (*tls.Certificate, error) {
+	data, err := os.ReadFile(c.certificateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate := tls.Certificate{PrivateKey: c.signer}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			certificate.Certificate = append(certificate.Certificate, block.Bytes)
+		}
+	}
+	if len(certificate.Certificate) == 0 {
+		return nil, errors.New("No certificate found in " + c.certificateFile)
+	}
+	return &certificate, nil
 }
@@ -1,18 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
 )
 
 type certificate struct {
 	certificateURL string
 	certificate    string
+	// alternates are the URLs of other chains for the same certificate
+	alternates []string
 }
 
-func (acme *acmeClient) getCertificate(certificateURL string) (*certificate, error) {
+func (acme *acmeClient) getCertificate(ctx context.Context, certificateURL string) (*certificate, error) {
 	/*
 			 POST /acme/cert/mAt3xBGaobw HTTP/1.1
 		   Host: example.com
@@ -62,21 +71,11 @@ func (acme *acmeClient) getCertificate(certificateURL string) (*certificate, err
 		"url": certificateURL,
 	}
 
-	req, err := acme.josePostRequest(certificateURL, headers, nil)
-	if err != nil {
-		logger.Error("Error creating certificate request: ", err)
-		return nil, err
-	}
-
-	req.Header.Set("Accept", "application/pem-certificate-chain")
-	req.Header.Set("Content-Type", "application/jose+json")
-
-	resp, err := acme.httpClient.Do(req)
+	resp, err := acme.doJosePostRequestAccept(ctx, certificateURL, headers, nil, "application/pem-certificate-chain")
 	if err != nil {
 		logger.Error("Error getting certificate: ", err)
 		return nil, err
 	}
-	acme.currentNonce = resp.Header.Get("Replay-Nonce")
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -84,13 +83,129 @@ func (acme *acmeClient) getCertificate(certificateURL string) (*certificate, err
 		return nil, err
 	}
 
+	if err := checkResponse(resp, body, 200); err != nil {
+		logger.WithError(err).Error("Error getting certificate")
+		return nil, err
+	}
+
 	return &certificate{
 		certificateURL: certificateURL,
 		certificate:    string(body),
+		alternates:     linkURLs(resp, "alternate"),
 	}, nil
 }
 
-func (acme *acmeClient) revokeCertificate(certificate *certificate) error {
+// getCertificateChains downloads the default chain and, if withAlternates is
+// set, every alternate chain the CA offers (RFC 8555, section 7.4.2). The
+// default chain comes first. Alternates that can't be downloaded are skipped,
+// the default chain is always usable.
+func (acme *acmeClient) getCertificateChains(ctx context.Context, certificateURL string, withAlternates bool) ([]*certificate, error) {
+	logger := acme.logger.WithField("method", "getCertificateChains")
+
+	cert, err := acme.getCertificate(ctx, certificateURL)
+	if err != nil {
+		return nil, err
+	}
+
+	chains := []*certificate{cert}
+	if !withAlternates {
+		return chains, nil
+	}
+	for _, alternateURL := range cert.alternates {
+		alternate, err := acme.getCertificate(ctx, alternateURL)
+		if err != nil {
+			logger.WithError(err).Warn("Skipping alternate chain ", alternateURL)
+			continue
+		}
+		chains = append(chains, alternate)
+	}
+	return chains, nil
+}
+
+// selectChain picks the first chain whose topmost certificate is issued by
+// preferredChain, matched against the issuer common name. Without a match the
+// default chain is used.
+func selectChain(logger *logrus.Entry, chains []*certificate, preferredChain string) *certificate {
+	if preferredChain == "" {
+		return chains[0]
+	}
+	for _, chain := range chains {
+		certs, err := parseChain(chain.certificate)
+		if err != nil {
+			logger.WithError(err).Warn("Skipping unparsable chain ", chain.certificateURL)
+			continue
+		}
+		if top := certs[len(certs)-1]; top.Issuer.CommonName == preferredChain {
+			logger.WithField("chain", chain.certificateURL).Info("Using chain issued by ", preferredChain)
+			return chain
+		}
+	}
+	logger.Warnf("No chain issued by %s, using the default chain", preferredChain)
+	return chains[0]
+}
+
+// parseChain decodes a PEM certificate chain, the end-entity certificate comes first
+func parseChain(chain string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(chain)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing certificate chain: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("No certificate found in PEM")
+	}
+	return certs, nil
+}
+
+// linkURLs returns the targets of the Link headers with relation rel, resolved against the request URL
+func linkURLs(resp *http.Response, rel string) []string {
+	var urls []string
+	for _, header := range resp.Header.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = target[1 : len(target)-1]
+
+			matches := false
+			for _, param := range parts[1:] {
+				name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+				if found && strings.EqualFold(name, "rel") {
+					for _, relation := range strings.Fields(strings.Trim(value, `"`)) {
+						matches = matches || strings.EqualFold(relation, rel)
+					}
+				}
+			}
+			if !matches {
+				continue
+			}
+
+			if resp.Request != nil {
+				if resolved, err := resp.Request.URL.Parse(target); err == nil {
+					target = resolved.String()
+				}
+			}
+			urls = append(urls, target)
+		}
+	}
+	return urls
+}
+
+func (acme *acmeClient) revokeCertificate(ctx context.Context, certificate *certificate) error {
 	/*
 			 POST /acme/revoke-cert HTTP/1.1
 		   Host: example.com
@@ -145,11 +260,40 @@ func (acme *acmeClient) revokeCertificate(certificate *certificate) error {
 		"kid": acme.accountURL,
 	}
 
-	_, err := acme.doJosePostRequest(acme.endpoints.RevokeCert, headers, payload)
+	resp, err := acme.doJosePostRequest(ctx, acme.endpoints.RevokeCert, headers, payload)
 	if err != nil {
 		logger.Error("Error revoking certificate: ", err)
 		return err
 	}
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error("Error reading response body: ", err)
+		return err
+	}
+
+	if err := checkResponse(resp, body, 200); err != nil {
+		logger.WithError(err).Error("Error revoking certificate")
+		return err
+	}
+
+	return nil
+}
+
+// saveCertificate adds the certificate to the lineage of the order's identifiers, if a state directory is configured
+func (acme *acmeClient) saveCertificate(order *Order, cert *certificate) error {
+	if acme.state == nil {
+		return nil
+	}
+
+	lineage, err := acme.state.Lineage(stateIdentifiers(order.identifiers))
+	if err != nil {
+		return err
+	}
+	version, err := acme.state.SaveCertificate(lineage, []byte(cert.certificate), cert.certificateURL, order.orderURL)
+	if err != nil {
+		return err
+	}
+	acme.logger.WithField("file", lineage.Path(version)).Info("Certificate saved to lineage ", lineage.Name)
 	return nil
 }
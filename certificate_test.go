@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestLinkURLs(t *testing.T) {
+	request := &http.Request{URL: &url.URL{Scheme: "https", Host: "ca.example", Path: "/acme/cert/1"}}
+
+	tests := []struct {
+		name   string
+		header []string
+		rel    string
+		want   []string
+	}{
+		{"no link", nil, "alternate", nil},
+		{
+			"rfc8555 example",
+			[]string{`<https://example.com/acme/directory>;rel="index"`, `<https://example.com/acme/cert/mAt3xBGaobw/1>;rel="alternate"`},
+			"alternate",
+			[]string{"https://example.com/acme/cert/mAt3xBGaobw/1"},
+		},
+		{
+			"several in one header",
+			[]string{`<https://ca.example/cert/1/1>; rel="alternate", <https://ca.example/cert/1/2>; rel="alternate"`},
+			"alternate",
+			[]string{"https://ca.example/cert/1/1", "https://ca.example/cert/1/2"},
+		},
+		{"relative target", []string{`</acme/cert/1/alt>;rel=alternate`}, "alternate", []string{"https://ca.example/acme/cert/1/alt"}},
+		{"several relations", []string{`<https://ca.example/up>;rel="up alternate"`}, "alternate", []string{"https://ca.example/up"}},
+		{"case insensitive", []string{`<https://ca.example/alt>;REL="Alternate"`}, "alternate", []string{"https://ca.example/alt"}},
+		{"other relation", []string{`<https://ca.example/dir>;rel="index"`}, "alternate", nil},
+		{"no brackets", []string{`https://ca.example/alt;rel="alternate"`}, "alternate", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{"Link": test.header}, Request: request}
+			if got := linkURLs(resp, test.rel); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("linkURLs = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -1,47 +1,69 @@
 package main
 
 import (
-	"crypto/ecdsa"
+	"context"
+	"crypto"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
+	"io"
 
+	"github.com/komplexon3/acme-client/acme"
 	"github.com/komplexon3/acme-client/jose"
 )
 
 type challenge struct {
-	Type  string `json:"type"`
-	Url   string `json:"url"`
-	Token string `json:"token"`
+	Type   string        `json:"type"`
+	Url    string        `json:"url"`
+	Token  string        `json:"token"`
+	Status string        `json:"status,omitempty"`
+	Error  *acme.Problem `json:"error,omitempty"`
 }
 
-func computeKeyauthorization(token string, key ecdsa.PublicKey) string {
-	// in params assuming key is an ecdsa key
-	jwk := jose.GetJWK(key)
-	jwkThumbprint := jwk.Thumbprint()
+func computeKeyauthorization(token string, key crypto.PublicKey) string {
+	jwk, err := jose.GetJWK(key)
+	if err != nil {
+		return ""
+	}
+	jwkThumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		return ""
+	}
 
 	return token + "." + base64.RawURLEncoding.EncodeToString(jwkThumbprint)
 }
 
-func (acme *acmeClient) registerDNSChallenge(domain string, chal *challenge) (chan bool, error) {
+// dnsChallengeRecord returns the name and value of the TXT record for a dns-01 challenge
+func (acme *acmeClient) dnsChallengeRecord(domain string, chal *challenge) (string, string, error) {
 	entry := "_acme-challenge." + domain + "."
-	challengeString := computeKeyauthorization(chal.Token, acme.privateKey.PublicKey)
+	challengeString := computeKeyauthorization(chal.Token, acme.privateKey.Public())
 	if challengeString == "" {
-		return nil, errors.New("Error computing key authorization")
+		return "", "", errors.New("Error computing key authorization")
 	}
 	digest := sha256.Sum256([]byte(challengeString))
-	digestString := base64.RawURLEncoding.EncodeToString(digest[:])
+	return entry, base64.RawURLEncoding.EncodeToString(digest[:]), nil
+}
 
+func (acme *acmeClient) registerDNSChallenge(domain string, chal *challenge) (chan bool, error) {
+	entry, digestString, err := acme.dnsChallengeRecord(domain, chal)
+	if err != nil {
+		return nil, err
+	}
 	return acme.dnsProvider.AddTXTRecord(entry, digestString)
 }
 
-func (acme *acmeClient) deregisterDNSChallenge(domain string) error {
-	entry := "_acme-challenge." + domain + "."
-	return acme.dnsProvider.DelTXTRecord(entry)
+// deregisterDNSChallenge only removes the record of this challenge, a wildcard
+// and its base domain may be validated at the same time
+func (acme *acmeClient) deregisterDNSChallenge(domain string, chal *challenge) error {
+	entry, digestString, err := acme.dnsChallengeRecord(domain, chal)
+	if err != nil {
+		return err
+	}
+	return acme.dnsProvider.DelTXTRecord(entry, digestString)
 }
 
 func (acme *acmeClient) registerHTTPChallenge(chal *challenge) (chan bool, error) {
-	challengeString := computeKeyauthorization(chal.Token, acme.privateKey.PublicKey)
+	challengeString := computeKeyauthorization(chal.Token, acme.privateKey.Public())
 	if challengeString == "" {
 		return nil, errors.New("Error computing key authorization")
 	}
@@ -53,18 +75,26 @@ func (acme *acmeClient) deregisterHTTPChallenge(chal *challenge) error {
 	return acme.httpChallengeProvider.DelChallengePath(chal.Token)
 }
 
-func (acme *acmeClient) respondToChallenge(chal *challenge) error {
+func (acme *acmeClient) respondToChallenge(ctx context.Context, chal *challenge) error {
 	headers := map[string]interface{}{
 		"kid": acme.accountURL,
 	}
 	payload := map[string]interface{}{}
-	_, err := acme.doJosePostRequest(chal.Url, headers, payload)
-	return err
+	resp, err := acme.doJosePostRequest(ctx, chal.Url, headers, payload)
+	if err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, body, 200)
 }
-func (acme *acmeClient) deregisterChallenge(chal *challenge) error {
+func (acme *acmeClient) deregisterChallenge(auth *authorization, chal *challenge) error {
 	switch chal.Type {
 	case "dns-01":
-		return acme.deregisterDNSChallenge(chal.Token)
+		return acme.deregisterDNSChallenge(auth.identifier.Value, chal)
 	case "http-01":
 		return acme.deregisterHTTPChallenge(chal)
 	}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/komplexon3/acme-client/jose"
+)
+
+// publicOnly is an account key of which only the public half is known
+type publicOnly struct {
+	key crypto.PublicKey
+}
+
+func (signer publicOnly) Public() crypto.PublicKey {
+	return signer.key
+}
+
+func (signer publicOnly) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, errors.New("Not a private key")
+}
+
+// rfc7638PublicKey is the RSA key of RFC 7638, section 3.1
+func rfc7638PublicKey(t *testing.T) crypto.PublicKey {
+	t.Helper()
+	jwk, err := jose.ParseJWK([]byte(`{"kty":"RSA","e":"AQAB","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeyAuthorization(t *testing.T) {
+	// the token of RFC 8555, section 8.3 with the thumbprint of the RFC 7638 key
+	const token = "evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA"
+	const keyAuthorization = token + ".NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"
+
+	acmeClient := &acmeClient{privateKey: publicOnly{rfc7638PublicKey(t)}}
+	chal := &challenge{Type: "dns-01", Token: token}
+
+	if got := computeKeyauthorization(token, acmeClient.privateKey.Public()); got != keyAuthorization {
+		t.Errorf("key authorization = %s, want %s", got, keyAuthorization)
+	}
+
+	tests := []struct {
+		domain   string
+		wantName string
+	}{
+		{"example.com", "_acme-challenge.example.com."},
+		{"www.example.com", "_acme-challenge.www.example.com."},
+	}
+	for _, test := range tests {
+		t.Run(test.domain, func(t *testing.T) {
+			name, value, err := acmeClient.dnsChallengeRecord(test.domain, chal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != test.wantName {
+				t.Errorf("record name = %s, want %s", name, test.wantName)
+			}
+			// base64url(SHA-256(key authorization)), RFC 8555, section 8.4
+			if want := "ZTRx1Ckl1-tM05o5zaizTTA0yUy5AGereMgSNWC6Ll8"; value != want {
+				t.Errorf("record value = %s, want %s", value, want)
+			}
+		})
+	}
+}
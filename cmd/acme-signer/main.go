@@ -0,0 +1,68 @@
+package main
+
+/*
+*	Small signer daemon that keeps a private key out of the memory of the ACME
+*	client. The client connects to it with --account-signer unix:<socket> or
+*	--cert-signer unix:<socket>.
+*
+*	Keyword arguments:
+*	--key KEY_FILE
+*	(required) File holding the private key as PEM (PKCS#8, SEC1 or PKCS#1) or JWK JSON.
+*	--socket SOCKET_PATH
+*	(required) Path of the unix socket the daemon listens on.
+ */
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	flags "github.com/jessevdk/go-flags"
+	"github.com/komplexon3/acme-client/keys"
+	"github.com/komplexon3/acme-client/signer"
+	"github.com/sirupsen/logrus"
+)
+
+type config struct {
+	Key    string `long:"key" description:"File holding the private key as PEM (PKCS#8, SEC1 or PKCS#1) or JWK JSON." required:"true"`
+	Socket string `long:"socket" description:"Path of the unix socket to listen on." required:"true"`
+}
+
+func main() {
+	logger := logrus.New().WithField("server", "signer")
+
+	var conf config
+	if _, err := flags.Parse(&conf); err != nil {
+		os.Exit(1)
+	}
+
+	key, err := keys.Load(conf.Key)
+	if err != nil {
+		logger.Fatalf("Error loading key: %v", err)
+	}
+
+	// a stale socket from a previous run would make Listen fail
+	os.Remove(conf.Socket)
+	listener, err := net.Listen("unix", conf.Socket)
+	if err != nil {
+		logger.Fatalf("Error listening on %s: %v", conf.Socket, err)
+	}
+	if err := os.Chmod(conf.Socket, 0600); err != nil {
+		logger.Fatalf("Error restricting access to %s: %v", conf.Socket, err)
+	}
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-shutdown
+		listener.Close()
+	}()
+
+	logger.WithField("socket", conf.Socket).Info("Signer listening")
+	if err := signer.Serve(listener, key, logger); err != nil && !errors.Is(err, net.ErrClosed) {
+		logger.Fatalf("Error serving: %v", err)
+	}
+	logger.Info("Shutting down...")
+}
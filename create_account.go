@@ -1,39 +1,177 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 
 	"github.com/komplexon3/acme-client/jose"
+	"github.com/komplexon3/acme-client/keys"
+	"github.com/komplexon3/acme-client/state"
 )
 
-func (acme *acmeClient) createAccount() error {
+func (acme *acmeClient) createAccount(ctx context.Context) error {
 	logger := acme.logger.WithField("method", "createAccount")
 	if acme.endpoints.NewAccount == "" {
 		logger.Error("No new account endpoint")
 		return errors.New("NewAccount endpoint not set")
 	}
 
-	jwk := jose.GetJWK(acme.privateKey.PublicKey)
+	jwk, err := jose.GetJWK(acme.privateKey.Public())
+	if err != nil {
+		logger.WithError(err).Error("Error creating JWK")
+		return err
+	}
 
-	payload := map[string]interface{}{
-		"termsOfServiceAgreed": true,
+	// reuse the saved account instead of burning the CA's new-account rate limit
+	if acme.savedAccount {
+		found, err := acme.lookupAccount(ctx, jwk)
+		if err != nil {
+			return err
+		}
+		if found {
+			logger.WithField("account", acme.accountURL).Info("Reusing existing account")
+			return acme.saveAccount()
+		}
+		logger.Info("Saved account does not exist on the server, registering a new one")
+	}
+
+	meta := acme.endpoints.Meta
+	if meta.TermsOfService != "" && !acme.agreeTOS {
+		logger.Error("Terms of service not agreed to")
+		return fmt.Errorf("The CA requires agreeing to its terms of service at %s, rerun with --agree-tos", meta.TermsOfService)
+	}
+	if meta.ExternalAccountRequired && acme.eab == nil {
+		logger.Error("External account binding required")
+		return errors.New("The CA requires an external account binding, rerun with --eab-kid and --eab-hmac-key")
+	}
+
+	payload := map[string]interface{}{}
+	if acme.agreeTOS {
+		payload["termsOfServiceAgreed"] = true
+	}
+	if len(acme.contacts) > 0 {
+		payload["contact"] = acme.contacts
+	}
+
+	if acme.eab != nil {
+		binding, err := jose.CreateEABPayload(acme.eab.kid, acme.eab.macKey, acme.eab.algorithm, acme.endpoints.NewAccount, jwk)
+		if err != nil {
+			logger.WithError(err).Error("Error creating external account binding")
+			return err
+		}
+		payload["externalAccountBinding"] = binding
 	}
 	headers := map[string]interface{}{
 		"jwk": jwk,
 	}
 
-	resp, err := acme.doJosePostRequest(acme.endpoints.NewAccount, headers, payload)
+	resp, err := acme.doJosePostRequest(ctx, acme.endpoints.NewAccount, headers, payload)
 	if err != nil {
 		logger.Error("Error creating account: ", err)
 		return err
 	}
 
-	if resp.StatusCode != 201 {
-		logger.Error("Error creating account: ", resp.Status)
-		return errors.New("Error creating account: " + resp.Status)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error("Error reading response body: ", err)
+		return err
+	}
+
+	// 200 means an account with this key already exists
+	if err := checkResponse(resp, body, 201, 200); err != nil {
+		logger.WithError(err).Error("Error creating account")
+		return err
 	}
 
 	// save the account URL
 	acme.accountURL = resp.Header.Get("Location")
+	return acme.saveAccount()
+}
+
+// findAccount looks up the account of the current key and fails if there is
+// none, it never registers a new account
+func (acme *acmeClient) findAccount(ctx context.Context) error {
+	logger := acme.logger.WithField("method", "findAccount")
+	if acme.endpoints.NewAccount == "" {
+		logger.Error("No new account endpoint")
+		return errors.New("NewAccount endpoint not set")
+	}
+
+	jwk, err := jose.GetJWK(acme.privateKey.Public())
+	if err != nil {
+		logger.WithError(err).Error("Error creating JWK")
+		return err
+	}
+
+	found, err := acme.lookupAccount(ctx, jwk)
+	if err != nil {
+		return err
+	}
+	if !found {
+		logger.Error("No account for the key")
+		return errors.New("No account exists for the account key, pass --account-key or --state-dir with an existing account")
+	}
+	return nil
+}
+
+// lookupAccount asks the server for the account belonging to the current key
+// without creating one (RFC 8555, section 7.3.1)
+func (acme *acmeClient) lookupAccount(ctx context.Context, jwk *jose.JWK) (bool, error) {
+	logger := acme.logger.WithField("method", "lookupAccount")
+
+	payload := map[string]interface{}{
+		"onlyReturnExisting": true,
+	}
+	headers := map[string]interface{}{
+		"jwk": jwk,
+	}
+
+	resp, err := acme.doJosePostRequest(ctx, acme.endpoints.NewAccount, headers, payload)
+	if err != nil {
+		logger.Error("Error looking up account: ", err)
+		return false, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error("Error reading response body: ", err)
+		return false, err
+	}
+
+	err = checkResponse(resp, body, 200)
+	if isProblem(err, accountDoesNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		logger.WithError(err).Error("Error looking up account")
+		return false, err
+	}
+
+	acme.accountURL = resp.Header.Get("Location")
+	return true, nil
+}
+
+// saveAccount persists the account URL and key if a state directory is configured
+func (acme *acmeClient) saveAccount() error {
+	if acme.state == nil {
+		return nil
+	}
+
+	// keys held by an external signer stay where they are
+	var key = acme.privateKey
+	if !keys.Exportable(key) {
+		key = nil
+	}
+
+	account := &state.Account{
+		URL:     acme.accountURL,
+		Contact: acme.contacts,
+	}
+	if err := acme.state.SaveAccount(account, key); err != nil {
+		acme.logger.WithError(err).Error("Error saving account")
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/komplexon3/acme-client/jose"
+)
+
+func TestCreateAccount(t *testing.T) {
+	const tos = "https://example.com/acme/terms/2017-5-30"
+	eab := &externalAccountBinding{kid: "kid-1", macKey: []byte("a MAC key handed out by the CA"), algorithm: "HS256"}
+
+	tests := []struct {
+		name     string
+		meta     directoryMeta
+		agreeTOS bool
+		eab      *externalAccountBinding
+		contacts []string
+		wantErr  bool
+		// wantPayload lists the members of the newAccount payload, nil if no request may be sent
+		wantPayload []string
+	}{
+		{"no terms", directoryMeta{}, false, nil, nil, false, []string{}},
+		{"terms not agreed", directoryMeta{TermsOfService: tos}, false, nil, nil, true, nil},
+		{"terms agreed", directoryMeta{TermsOfService: tos}, true, nil, nil, false, []string{"termsOfServiceAgreed"}},
+		{"contacts", directoryMeta{}, false, nil, []string{"mailto:admin@example.com"}, false, []string{"contact"}},
+		{"external account required", directoryMeta{ExternalAccountRequired: true}, false, nil, nil, true, nil},
+		{"external account binding", directoryMeta{TermsOfService: tos, ExternalAccountRequired: true}, true, eab, nil, false, []string{"termsOfServiceAgreed", "externalAccountBinding"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var payload map[string]json.RawMessage
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				jws, err := jose.Parse(body)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// newAccount is signed with the jwk in the header
+				if err := jws.Verify(nil); err != nil {
+					t.Error(err)
+				}
+				if err := jws.UnmarshalPayload(&payload); err != nil {
+					t.Error(err)
+				}
+				w.Header().Set("Location", "https://example.com/acme/acct/evOfKhNU60wg")
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer server.Close()
+
+			acmeClient := testClient(t, server)
+			acmeClient.accountURL = ""
+			acmeClient.endpoints.NewAccount = server.URL + "/new-account"
+			acmeClient.endpoints.Meta = test.meta
+			acmeClient.agreeTOS = test.agreeTOS
+			acmeClient.eab = test.eab
+			acmeClient.contacts = test.contacts
+
+			err := acmeClient.createAccount(context.Background())
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.wantPayload == nil {
+				if payload != nil {
+					t.Fatalf("newAccount request sent with %v", payload)
+				}
+				return
+			}
+			if acmeClient.accountURL != "https://example.com/acme/acct/evOfKhNU60wg" {
+				t.Errorf("account URL = %s", acmeClient.accountURL)
+			}
+			members := map[string]bool{}
+			for name := range payload {
+				members[name] = true
+			}
+			want := map[string]bool{}
+			for _, name := range test.wantPayload {
+				want[name] = true
+			}
+			if !reflect.DeepEqual(members, want) {
+				t.Errorf("payload = %v, want members %v", payload, test.wantPayload)
+			}
+			if test.agreeTOS && string(payload["termsOfServiceAgreed"]) != "true" {
+				t.Errorf("termsOfServiceAgreed = %s", payload["termsOfServiceAgreed"])
+			}
+			if test.contacts != nil {
+				var contacts []string
+				if err := json.Unmarshal(payload["contact"], &contacts); err != nil || !reflect.DeepEqual(contacts, test.contacts) {
+					t.Errorf("contact = %s, want %v", payload["contact"], test.contacts)
+				}
+			}
+			if test.eab != nil {
+				var binding jose.JWTDTO
+				if err := json.Unmarshal(payload["externalAccountBinding"], &binding); err != nil || binding.Signature == "" {
+					t.Errorf("externalAccountBinding = %s", payload["externalAccountBinding"])
+				}
+			}
+		})
+	}
+}
@@ -49,8 +49,9 @@ func (dnsServer *DNSServer) AddTXTRecord(domain string, value string) (chan bool
 	return dnsServer.store.Set(domain, value)
 }
 
-func (dnsServer *DNSServer) DelTXTRecord(domain string) error {
-	return dnsServer.store.Del(domain)
+// DelTXTRecord removes one TXT record of domain, other records of the same name are kept
+func (dnsServer *DNSServer) DelTXTRecord(domain string, value string) error {
+	return dnsServer.store.DelValue(domain, value)
 }
 
 func (dnsServer *DNSServer) Start() error {
@@ -68,8 +69,8 @@ func (dnsHandler *DNSHandler) ServeDNS(w miekg_dns.ResponseWriter, r *miekg_dns.
 	switch r.Question[0].Qtype {
 	case miekg_dns.TypeTXT:
 		domain := msg.Question[0].Name
-		res := dnsHandler.store.Get(domain)
-		if res != "" {
+		// a wildcard and its base domain are validated with records of the same name
+		for _, res := range dnsHandler.store.GetAll(domain) {
 			msg.Answer = append(msg.Answer, &miekg_dns.TXT{
 				Hdr: miekg_dns.RR_Header{Name: domain, Rrtype: miekg_dns.TypeTXT, Class: miekg_dns.ClassINET, Ttl: 300},
 				Txt: []string{res},
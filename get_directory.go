@@ -1,18 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 )
 
-func getDirectory(client http.Client, dir string) (*acmeEndpoints, error) {
+func getDirectory(ctx context.Context, client http.Client, dir string) (*acmeEndpoints, error) {
 	if dir == "" {
 		return nil, errors.New("Directory URL not set")
 	}
 
-	req, err := http.NewRequest("GET", dir, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", dir, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/komplexon3/acme-client/cassette"
+	"github.com/komplexon3/acme-client/trace"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// clientOptions describes how the connection to the ACME server is secured
+type clientOptions struct {
+	// caBundle is a PEM file or a directory of PEM files with additional roots
+	caBundle string
+	// noSystemRoots only trusts the roots of caBundle
+	noSystemRoots bool
+	// clientCert and clientKey authenticate the client to CAs behind mTLS gateways
+	clientCert string
+	clientKey  string
+	// pins restrict the server certificate to the given SHA-256 hashes of its DER or SPKI
+	pins []string
+	// proxy is the URL of an HTTP(S) CONNECT or SOCKS5 proxy, credentials go into
+	// its user info. If empty, HTTPS_PROXY and NO_PROXY are honoured.
+	proxy string
+	// insecureSkipVerify turns off certificate verification, only for debugging with MITM tools
+	insecureSkipVerify bool
+	// traceFile records all exchanges in traceFormat, see package trace
+	traceFile   string
+	traceFormat string
+	// cassette records exchanges to or replays them from this file, depending on cassetteMode
+	cassette     string
+	cassetteMode string
+}
+
+func setupClient(logger *logrus.Entry, options clientOptions) (*http.Client, error) {
+	rootCAs, err := loadRoots(options.caBundle, options.noSystemRoots)
+	if err != nil {
+		return nil, err
+	}
+
+	// Trust the augmented cert pool in our client
+	config := &tls.Config{
+		InsecureSkipVerify: options.insecureSkipVerify,
+		RootCAs:            rootCAs,
+	}
+
+	if options.clientCert != "" || options.clientKey != "" {
+		if options.clientCert == "" || options.clientKey == "" {
+			return nil, errors.New("Client certificate and key must be given together")
+		}
+		clientCert, err := tls.LoadX509KeyPair(options.clientCert, options.clientKey)
+		if err != nil {
+			return nil, errors.New("Failed to load client certificate: " + err.Error())
+		}
+		config.Certificates = []tls.Certificate{clientCert}
+	}
+
+	if len(options.pins) > 0 {
+		pins, err := parsePins(options.pins)
+		if err != nil {
+			return nil, err
+		}
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return checkPins(state.PeerCertificates, pins)
+		}
+	}
+
+	proxy, err := proxyFunc(options.proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	transport.Proxy = proxy
+
+	var roundTripper http.RoundTripper = transport
+
+	switch {
+	case options.cassette == "":
+	case options.cassetteMode == cassette.Replay:
+		roundTripper, err = cassette.NewReplayer(options.cassette)
+	default:
+		roundTripper, err = cassette.NewRecorder(transport, options.cassette)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the trace wraps the cassette, so that replayed runs can be traced as well
+	if options.traceFile != "" {
+		roundTripper, err = trace.NewTransport(logger, roundTripper, options.traceFile, options.traceFormat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &http.Client{Transport: roundTripper}, nil
+}
+
+// proxyFunc picks the proxy for every request. TLS to the ACME server is
+// tunneled through the proxy, so the server certificate is still verified.
+func proxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" {
+		// unlike http.ProxyFromEnvironment, which reads the environment once per
+		// process, this reads it whenever a client is set up
+		proxyForURL := httpproxy.FromEnvironment().ProxyFunc()
+		return func(req *http.Request) (*url.URL, error) {
+			return proxyForURL(req.URL)
+		}, nil
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, errors.New("Failed to parse proxy URL: " + err.Error())
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("Unsupported proxy scheme %q, use http, https or socks5", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, errors.New("Proxy URL " + proxyURL.Redacted() + " has no host")
+	}
+
+	return http.ProxyURL(proxyURL), nil
+}
+
+// loadRoots builds the pool of trusted roots from the system roots and the
+// certificates in bundle, which is either a PEM file or a directory of them
+func loadRoots(bundle string, noSystemRoots bool) (*x509.CertPool, error) {
+	var rootCAs *x509.CertPool
+	if !noSystemRoots {
+		rootCAs, _ = x509.SystemCertPool()
+	}
+	if rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+
+	if bundle == "" {
+		if noSystemRoots {
+			return nil, errors.New("No trusted roots left, --no-system-roots needs --ca-bundle")
+		}
+		return rootCAs, nil
+	}
+
+	info, err := os.Stat(bundle)
+	if err != nil {
+		return nil, errors.New("Failed to read CA bundle: " + err.Error())
+	}
+
+	files := []string{bundle}
+	if info.IsDir() {
+		entries, err := os.ReadDir(bundle)
+		if err != nil {
+			return nil, errors.New("Failed to read CA bundle: " + err.Error())
+		}
+		files = nil
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".pem", ".crt", ".cer":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(bundle, entry.Name()))
+				}
+			}
+		}
+	}
+
+	appended := 0
+	for _, file := range files {
+		certs, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.New("Failed to append " + file + " to RootCAs: " + err.Error())
+		}
+		// blocks other than certificates are skipped, a directory may also hold e.g. keys
+		if ok := rootCAs.AppendCertsFromPEM(certs); !ok {
+			if info.IsDir() {
+				continue
+			}
+			return nil, errors.New("Failed to append " + file + " to RootCAs")
+		}
+		appended++
+	}
+	if appended == 0 {
+		return nil, errors.New("No certificates found in " + bundle)
+	}
+
+	return rootCAs, nil
+}
+
+// parsePins decodes pins of the form sha256/<base64 hash>
+func parsePins(pins []string) ([][]byte, error) {
+	decoded := make([][]byte, len(pins))
+	for i, pin := range pins {
+		encoded := strings.TrimPrefix(pin, "sha256/")
+		if encoded == pin {
+			return nil, fmt.Errorf("Pin %s must have the form sha256/<base64 hash>", pin)
+		}
+		hash, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("Pin %s is not a base64 encoded SHA-256 hash", pin)
+		}
+		decoded[i] = hash
+	}
+	return decoded, nil
+}
+
+// checkPins accepts the server certificate if the hash of its DER encoding or of its SPKI is pinned
+func checkPins(peerCertificates []*x509.Certificate, pins [][]byte) error {
+	if len(peerCertificates) == 0 {
+		return errors.New("Server sent no certificate")
+	}
+	leaf := peerCertificates[0]
+	certHash := sha256.Sum256(leaf.Raw)
+	spkiHash := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+	for _, pin := range pins {
+		if bytes.Equal(pin, certHash[:]) || bytes.Equal(pin, spkiHash[:]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Server certificate matches none of the pins, its SPKI pin is sha256/%s", base64.StdEncoding.EncodeToString(spkiHash[:]))
+}
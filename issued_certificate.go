@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// validateCertificate checks the issued chain before anything is written: the
+// leaf must be for key, cover every requested domain and, unless roots is nil,
+// chain up to one of roots through the intermediates the CA sent along.
+func validateCertificate(certs []*x509.Certificate, key crypto.PublicKey, domains []string, roots *x509.CertPool) error {
+	leaf := certs[0]
+
+	publicKey, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(key) {
+		return fmt.Errorf("Certificate %s is not for the key of the CSR", leaf.SerialNumber)
+	}
+
+	names := make(map[string]bool, len(leaf.DNSNames))
+	for _, name := range leaf.DNSNames {
+		names[strings.ToLower(name)] = true
+	}
+	var missing []string
+	for _, domain := range domains {
+		if !names[strings.ToLower(domain)] {
+			missing = append(missing, domain)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Certificate %s does not cover %s", leaf.SerialNumber, strings.Join(missing, ", "))
+	}
+
+	if roots == nil {
+		return nil
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}); err != nil {
+		return fmt.Errorf("Certificate chain does not verify: %v", err)
+	}
+	return nil
+}
+
+// encodeCertificates PEM encodes certs in order
+func encodeCertificates(certs []*x509.Certificate) []byte {
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.Bytes()
+}
+
+// writeCertificateFiles writes the end-entity certificate to cert.pem, the
+// intermediates to chain.pem and both to fullchain.pem
+func writeCertificateFiles(certs []*x509.Certificate) error {
+	files := []struct {
+		name  string
+		certs []*x509.Certificate
+	}{
+		{"cert.pem", certs[:1]},
+		{"chain.pem", certs[1:]},
+		{"fullchain.pem", certs},
+	}
+	for _, file := range files {
+		if err := os.WriteFile(file.name, encodeCertificates(file.certs), 0644); err != nil {
+			return fmt.Errorf("Error writing %s: %v", file.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// issueCertificate signs a certificate for pub with the CA given as PEM and key
+func issueCertificate(t *testing.T, caPEM []byte, caKey crypto.Signer, pub crypto.PublicKey, template *x509.Certificate) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(caPEM)
+	ca, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, pub, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestValidateCertificate(t *testing.T) {
+	root, rootKey := selfSignedCA(t, "test root")
+	otherRoot, _ := selfSignedCA(t, "other root")
+
+	intermediateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate := issueCertificate(t, root, rootKey, intermediateKey.Public(), &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test intermediate"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	intermediatePEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediate.Raw})
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := issueCertificate(t, intermediatePEM, intermediateKey, key.Public(), &x509.Certificate{
+		DNSNames:    []string{"example.com", "*.example.com"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+
+	tests := []struct {
+		name    string
+		certs   []*x509.Certificate
+		key     crypto.PublicKey
+		domains []string
+		roots   *x509.CertPool
+		wantErr bool
+	}{
+		{"valid", []*x509.Certificate{leaf, intermediate}, key.Public(), []string{"example.com", "*.example.com"}, rootPool(t, root), false},
+		{"names differ in case", []*x509.Certificate{leaf, intermediate}, key.Public(), []string{"Example.COM"}, rootPool(t, root), false},
+		{"key mismatch", []*x509.Certificate{leaf, intermediate}, otherKey.Public(), []string{"example.com"}, rootPool(t, root), true},
+		{"missing name", []*x509.Certificate{leaf, intermediate}, key.Public(), []string{"example.com", "example.net"}, rootPool(t, root), true},
+		{"untrusted root", []*x509.Certificate{leaf, intermediate}, key.Public(), []string{"example.com"}, rootPool(t, otherRoot), true},
+		{"missing intermediate", []*x509.Certificate{leaf}, key.Public(), []string{"example.com"}, rootPool(t, root), true},
+		// --no-verify-chain still checks the key and the names
+		{"no chain verification", []*x509.Certificate{leaf}, key.Public(), []string{"example.com"}, nil, false},
+		{"no chain verification, key mismatch", []*x509.Certificate{leaf}, otherKey.Public(), []string{"example.com"}, nil, true},
+		{"no chain verification, missing name", []*x509.Certificate{leaf}, key.Public(), []string{"example.net"}, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateCertificate(test.certs, test.key, test.domains, test.roots)
+			if test.wantErr && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !test.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package jose
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+func hmacHash(alg string) (func() hash.Hash, error) {
+	switch alg {
+	case "HS256":
+		return sha256.New, nil
+	case "HS384":
+		return sha512.New384, nil
+	case "HS512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("Unsupported MAC algorithm %s", alg)
+	}
+}
+
+// CreateEABPayload creates the externalAccountBinding JWS for a newAccount request
+// (RFC 8555, section 7.3.4). The payload is the account JWK, MACed with the key
+// the CA handed out together with kid.
+func CreateEABPayload(kid string, macKey []byte, alg string, url string, jwk *JWK) (*JWTDTO, error) {
+	if kid == "" || len(macKey) == 0 {
+		return nil, errors.New("EAB requires a key identifier and a MAC key")
+	}
+	newHash, err := hmacHash(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := SerializeSegment(map[string]interface{}{
+		"alg": alg,
+		"kid": kid,
+		"url": url,
+	})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := SerializeSegment(jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(newHash, macKey)
+	mac.Write([]byte(header + "." + payload))
+
+	return &JWTDTO{
+		Protected: header,
+		Payload:   payload,
+		Signature: base64.RawURLEncoding.EncodeToString(mac.Sum(nil)),
+	}, nil
+}
@@ -0,0 +1,83 @@
+package jose
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"testing"
+)
+
+func TestCreateEABPayload(t *testing.T) {
+	macKey := []byte("a MAC key handed out by the CA")
+	url := "https://example.com/acme/new-account"
+
+	tests := []struct {
+		name    string
+		kid     string
+		macKey  []byte
+		alg     string
+		hash    func() hash.Hash
+		wantErr bool
+	}{
+		{"HS256", "kid-1", macKey, "HS256", sha256.New, false},
+		{"HS384", "kid-1", macKey, "HS384", sha512.New384, false},
+		{"HS512", "kid-1", macKey, "HS512", sha512.New, false},
+		{"unsupported alg", "kid-1", macKey, "RS256", nil, true},
+		{"no kid", "", macKey, "HS256", nil, true},
+		{"no mac key", "kid-1", nil, "HS256", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			binding, err := CreateEABPayload(test.kid, test.macKey, test.alg, url, &rfc7638Key)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// RFC 8555, section 7.3.4: alg, kid and url in the header, no nonce
+			var header map[string]interface{}
+			decodeSegment(t, binding.Protected, &header)
+			want := map[string]interface{}{"alg": test.alg, "kid": test.kid, "url": url}
+			if len(header) != len(want) {
+				t.Errorf("header = %v, want %v", header, want)
+			}
+			for name, value := range want {
+				if header[name] != value {
+					t.Errorf("header %s = %v, want %v", name, header[name], value)
+				}
+			}
+
+			// the payload is the account key
+			var payload JWK
+			decodeSegment(t, binding.Payload, &payload)
+			if payload != rfc7638Key {
+				t.Errorf("payload = %+v, want the account JWK", payload)
+			}
+
+			mac := hmac.New(test.hash, test.macKey)
+			mac.Write([]byte(binding.Protected + "." + binding.Payload))
+			if want := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)); binding.Signature != want {
+				t.Errorf("signature = %s, want %s", binding.Signature, want)
+			}
+		})
+	}
+}
+
+func decodeSegment(t *testing.T, segment string, v interface{}) {
+	t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,12 +1,19 @@
 package jose
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/sha256"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"strings"
 )
 
@@ -21,13 +28,6 @@ type JWTDTO struct {
 	Signature string `json:"signature"`
 }
 
-type JWK struct {
-	Kty string `json:"kty"`
-	Crv string `json:"crv"`
-	X   string `json:"x"`
-	Y   string `json:"y"`
-}
-
 func SerializeSegment(data interface{}) (string, error) {
 	if data == nil {
 		return "", nil
@@ -40,24 +40,46 @@ func SerializeSegment(data interface{}) (string, error) {
 	return strings.TrimRight(base64.RawURLEncoding.EncodeToString(json), "="), nil
 }
 
-func GetJWK(key ecdsa.PublicKey) *JWK {
-	jwk := &JWK{
-		Kty: "EC",
-		Crv: "P-256",
-		X:   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
-		Y:   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+// Algorithm returns the JWS "alg" value used when signing with the private half of the given key
+func Algorithm(key crypto.PublicKey) (string, error) {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecAlgorithm(key.Curve)
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case ed25519.PublicKey:
+		return "EdDSA", nil
+	default:
+		return "", fmt.Errorf("Unsupported key type %T", key)
 	}
-	return jwk
 }
 
-func (jwk *JWK) Thumbprint() []byte {
-	thumbprint := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, jwk.X, jwk.Y)
-	h := sha256.New()
-	h.Write([]byte(thumbprint))
-	return h.Sum(nil)
+func ecAlgorithm(curve elliptic.Curve) (string, error) {
+	switch curve {
+	case elliptic.P256():
+		return "ES256", nil
+	case elliptic.P384():
+		return "ES384", nil
+	case elliptic.P521():
+		return "ES512", nil
+	default:
+		return "", fmt.Errorf("Unsupported curve %s", curve.Params().Name)
+	}
 }
 
-func (jwt *JWT) SignJWT(key *ecdsa.PrivateKey, nonce string) (string, error) {
+// hashForAlgorithm returns the digest used by an algorithm returned by Algorithm
+func hashForAlgorithm(alg string) crypto.Hash {
+	switch alg {
+	case "ES384":
+		return crypto.SHA384
+	case "ES512":
+		return crypto.SHA512
+	default:
+		return crypto.SHA256
+	}
+}
+
+func (jwt *JWT) SignJWT(key crypto.Signer, nonce string) (string, error) {
 	header, err := SerializeSegment(jwt.Header)
 	if err != nil {
 		return "", err
@@ -67,25 +89,67 @@ func (jwt *JWT) SignJWT(key *ecdsa.PrivateKey, nonce string) (string, error) {
 		return "", err
 	}
 
-	digest := sha256.Sum256([]byte(header + "." + payload))
-
-	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	alg, err := Algorithm(key.Public())
 	if err != nil {
 		return "", err
 	}
+	signingInput := []byte(header + "." + payload)
+
+	// the key may live outside of this process, so everything goes through crypto.Signer
+	var signature []byte
+	switch publicKey := key.Public().(type) {
+	case *ecdsa.PublicKey:
+		hash := hashForAlgorithm(alg)
+		h := hash.New()
+		h.Write(signingInput)
+		der, err := key.Sign(rand.Reader, h.Sum(nil), hash)
+		if err != nil {
+			return "", err
+		}
+
+		// crypto.Signer returns an ASN.1 signature, JWS wants r||s with both
+		// padded to the size of the curve (RFC 7518, section 3.4)
+		var sig struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(der, &sig); err != nil {
+			return "", fmt.Errorf("Error decoding ECDSA signature: %v", err)
+		}
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		sig.R.FillBytes(signature[:size])
+		sig.S.FillBytes(signature[size:])
+	case *rsa.PublicKey:
+		hash := hashForAlgorithm(alg)
+		h := hash.New()
+		h.Write(signingInput)
+		signature, err = key.Sign(rand.Reader, h.Sum(nil), hash)
+		if err != nil {
+			return "", err
+		}
+	case ed25519.PublicKey:
+		// EdDSA signs the message itself, not a digest
+		signature, err = key.Sign(rand.Reader, signingInput, crypto.Hash(0))
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("Unsupported key type %T", publicKey)
+	}
 
-	signature := make([]byte, 64)
-	r.FillBytes(signature[:32])
-	s.FillBytes(signature[32:])
-
-	enc := base64.RawURLEncoding.EncodeToString(signature)
-	_ = enc
 	return base64.RawURLEncoding.EncodeToString(signature), nil
 }
 
-func (jwt *JWT) CreateSignedPayload(key ecdsa.PrivateKey, nonce string) ([]byte, error) {
-	jwt.Header["alg"] = "ES256"
-	jwt.Header["nonce"] = nonce
+func (jwt *JWT) CreateSignedPayload(key crypto.Signer, nonce string) ([]byte, error) {
+	alg, err := Algorithm(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	jwt.Header["alg"] = alg
+	// the inner JWS of a key change request must not carry a nonce (RFC 8555, section 7.3.5)
+	if nonce != "" {
+		jwt.Header["nonce"] = nonce
+	}
 
 	header, err := SerializeSegment(jwt.Header)
 	if err != nil {
@@ -96,7 +160,7 @@ func (jwt *JWT) CreateSignedPayload(key ecdsa.PrivateKey, nonce string) ([]byte,
 		return nil, err
 	}
 
-	signature, err := jwt.SignJWT(&key, nonce)
+	signature, err := jwt.SignJWT(key, nonce)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,196 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWK is a JSON Web Key (RFC 7517) holding the public part of an EC, RSA or OKP key
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+func curveByName(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("Unsupported curve %s", name)
+	}
+}
+
+// coordinateSize returns the number of bytes a coordinate on the curve is padded to (RFC 7518, section 6.2.1.2)
+func coordinateSize(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
+
+func GetJWK(key crypto.PublicKey) (*JWK, error) {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if _, err := curveByName(key.Curve.Params().Name); err != nil {
+			return nil, err
+		}
+		size := coordinateSize(key.Curve)
+		return &JWK{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case *rsa.PublicKey:
+		return &JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return &JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported key type %T", key)
+	}
+}
+
+// requiredMembers returns the members of the key that take part in the thumbprint (RFC 7638, section 3.2)
+func (jwk *JWK) requiredMembers() (map[string]string, error) {
+	switch jwk.Kty {
+	case "EC":
+		return map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X, "y": jwk.Y}, nil
+	case "RSA":
+		return map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N}, nil
+	case "OKP":
+		return map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported key type %s", jwk.Kty)
+	}
+}
+
+// Thumbprint computes the SHA-256 JWK thumbprint as defined in RFC 7638
+func (jwk *JWK) Thumbprint() ([]byte, error) {
+	members, err := jwk.requiredMembers()
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range members {
+		if value == "" {
+			return nil, fmt.Errorf("JWK is missing member %s", name)
+		}
+	}
+
+	// encoding/json writes map keys in lexicographic order and the values are
+	// base64url or fixed names, so this is the canonical form without whitespace
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(canonical)
+	return digest[:], nil
+}
+
+func decodeMember(name string, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("JWK is missing member %s", name)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid JWK member %s: %v", name, err)
+	}
+	return raw, nil
+}
+
+// PublicKey converts the JWK back into an *ecdsa.PublicKey, *rsa.PublicKey or ed25519.PublicKey
+func (jwk *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "EC":
+		curve, err := curveByName(jwk.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := decodeMember("x", jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeMember("y", jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := coordinateSize(curve)
+		if len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("JWK coordinates must be %d bytes long for %s", size, jwk.Crv)
+		}
+		key := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("JWK point is not on the curve")
+		}
+		return key, nil
+	case "RSA":
+		n, err := decodeMember("n", jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeMember("e", jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 || exponent.Int64() < 3 {
+			return nil, errors.New("Invalid RSA exponent in JWK")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(exponent.Int64()),
+		}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("Unsupported curve %s", jwk.Crv)
+		}
+		x, err := decodeMember("x", jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Ed25519 JWK must be %d bytes long", ed25519.PublicKeySize)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("Unsupported key type %s", jwk.Kty)
+	}
+}
+
+// ParseJWK decodes a JSON encoded JWK
+func ParseJWK(data []byte) (*JWK, error) {
+	var jwk JWK
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		return nil, err
+	}
+	if _, err := jwk.PublicKey(); err != nil {
+		return nil, err
+	}
+	return &jwk, nil
+}
@@ -0,0 +1,83 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// privateJWK holds the private members of EC, RSA and OKP keys (RFC 7518, section 6)
+type privateJWK struct {
+	JWK
+	D  string `json:"d"`
+	P  string `json:"p"`
+	Q  string `json:"q"`
+	DP string `json:"dp"`
+	DQ string `json:"dq"`
+	QI string `json:"qi"`
+}
+
+// ParsePrivateJWK decodes a JSON encoded private JWK
+func ParsePrivateJWK(data []byte) (crypto.Signer, error) {
+	var jwk privateJWK
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		return nil, err
+	}
+
+	publicKey, err := jwk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	d, err := decodeMember("d", jwk.D)
+	if err != nil {
+		return nil, err
+	}
+
+	switch publicKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		key := &ecdsa.PrivateKey{
+			PublicKey: *publicKey,
+			D:         new(big.Int).SetBytes(d),
+		}
+		x, y := key.Curve.ScalarBaseMult(d)
+		if x.Cmp(key.X) != 0 || y.Cmp(key.Y) != 0 {
+			return nil, errors.New("JWK private key does not match public key")
+		}
+		return key, nil
+	case *rsa.PublicKey:
+		p, err := decodeMember("p", jwk.P)
+		if err != nil {
+			return nil, err
+		}
+		q, err := decodeMember("q", jwk.Q)
+		if err != nil {
+			return nil, err
+		}
+		key := &rsa.PrivateKey{
+			PublicKey: *publicKey,
+			D:         new(big.Int).SetBytes(d),
+			Primes:    []*big.Int{new(big.Int).SetBytes(p), new(big.Int).SetBytes(q)},
+		}
+		if err := key.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid RSA JWK: %v", err)
+		}
+		key.Precompute()
+		return key, nil
+	case ed25519.PublicKey:
+		if len(d) != ed25519.SeedSize {
+			return nil, fmt.Errorf("Ed25519 JWK must have a %d byte private key", ed25519.SeedSize)
+		}
+		key := ed25519.NewKeyFromSeed(d)
+		if !publicKey.Equal(key.Public()) {
+			return nil, errors.New("JWK private key does not match public key")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("Unsupported key type %T", publicKey)
+	}
+}
@@ -0,0 +1,138 @@
+package jose
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+// rfc7638Key is the RSA key of RFC 7638, section 3.1
+var rfc7638Key = JWK{
+	Kty: "RSA",
+	N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRX" +
+		"jBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8" +
+		"KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xB" +
+		"niIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	E: "AQAB",
+}
+
+// rfc8037Key is the Ed25519 key of RFC 8037, appendix A.2
+var rfc8037Key = JWK{
+	Kty: "OKP",
+	Crv: "Ed25519",
+	X:   "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+}
+
+func TestThumbprint(t *testing.T) {
+	tests := []struct {
+		name    string
+		jwk     JWK
+		want    string
+		wantErr bool
+	}{
+		// RFC 7638, section 3.1
+		{"rfc7638 rsa", rfc7638Key, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", false},
+		// RFC 8037, appendix A.3
+		{"rfc8037 ed25519", rfc8037Key, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", false},
+		{"missing member", JWK{Kty: "EC", Crv: "P-256", X: "AAAA"}, "", true},
+		{"unknown kty", JWK{Kty: "oct"}, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			thumbprint, err := test.jwk.Thumbprint()
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := base64.RawURLEncoding.EncodeToString(thumbprint); got != test.want {
+				t.Errorf("thumbprint = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// keyWithShortX generates keys until the x coordinate has a leading zero byte,
+// which GetJWK has to pad back to the full coordinate size
+func keyWithShortX(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+	size := coordinateSize(curve)
+	for i := 0; i < 100000; i++ {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(key.X.Bytes()) < size {
+			return key
+		}
+	}
+	t.Fatal("no key with a short x coordinate found")
+	return nil
+}
+
+func TestGetJWKPadsCoordinates(t *testing.T) {
+	tests := []struct {
+		curve elliptic.Curve
+		size  int
+	}{
+		{elliptic.P256(), 32},
+		{elliptic.P384(), 48},
+		{elliptic.P521(), 66},
+	}
+	for _, test := range tests {
+		t.Run(test.curve.Params().Name, func(t *testing.T) {
+			key := keyWithShortX(t, test.curve)
+			jwk, err := GetJWK(&key.PublicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range map[string]string{"x": jwk.X, "y": jwk.Y} {
+				decoded, err := base64.RawURLEncoding.DecodeString(value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(decoded) != test.size {
+					t.Errorf("%s is %d bytes long, want %d", name, len(decoded), test.size)
+				}
+			}
+
+			// and the padded form is read back as the same key
+			public, err := jwk.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !key.PublicKey.Equal(public) {
+				t.Error("JWK does not round-trip to the same key")
+			}
+		})
+	}
+}
+
+func TestParseJWK(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"rsa", `{"kty":"RSA","n":"` + rfc7638Key.N + `","e":"AQAB"}`, false},
+		{"ed25519", `{"kty":"OKP","crv":"Ed25519","x":"` + rfc8037Key.X + `"}`, false},
+		{"unpadded ec coordinate", `{"kty":"EC","crv":"P-256","x":"AAAA","y":"AAAA"}`, true},
+		{"unsupported curve", `{"kty":"EC","crv":"P-224","x":"AAAA","y":"AAAA"}`, true},
+		{"short ed25519 key", `{"kty":"OKP","crv":"Ed25519","x":"AAAA"}`, true},
+		{"not json", `jwk`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseJWK([]byte(test.data))
+			if (err != nil) != test.wantErr {
+				t.Errorf("err = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
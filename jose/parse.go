@@ -0,0 +1,191 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWS is a decoded flattened JWS as sent in the body of an ACME request
+type JWS struct {
+	Header    map[string]interface{}
+	Payload   []byte
+	Signature []byte
+
+	// the encoded segments are kept around since the signature is computed over them
+	protected string
+	payload   string
+}
+
+// Parse decodes a flattened JWS and checks that its protected header is a valid
+// ACME header (RFC 8555, section 6.2). The signature is not checked, use Verify for that.
+func Parse(data []byte) (*JWS, error) {
+	var dto JWTDTO
+	if err := json.Unmarshal(data, &dto); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling JWS: %v", err)
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(dto.Protected)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding protected header: %v", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(dto.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding payload: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(dto.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding signature: %v", err)
+	}
+
+	var header map[string]interface{}
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling protected header: %v", err)
+	}
+
+	jws := &JWS{
+		Header:    header,
+		Payload:   payload,
+		Signature: signature,
+		protected: dto.Protected,
+		payload:   dto.Payload,
+	}
+
+	switch alg := jws.Algorithm(); alg {
+	case "ES256", "ES384", "ES512", "RS256", "EdDSA":
+	case "":
+		return nil, errors.New("Protected header is missing alg")
+	default:
+		return nil, fmt.Errorf("Unsupported alg %s", alg)
+	}
+
+	_, hasJWK := header["jwk"]
+	_, hasKID := header["kid"]
+	if hasJWK == hasKID {
+		return nil, errors.New("Protected header must contain exactly one of jwk and kid")
+	}
+	if jws.Nonce() == "" {
+		return nil, errors.New("Protected header is missing nonce")
+	}
+	if jws.URL() == "" {
+		return nil, errors.New("Protected header is missing url")
+	}
+
+	return jws, nil
+}
+
+func (jws *JWS) headerString(name string) string {
+	value, _ := jws.Header[name].(string)
+	return value
+}
+
+func (jws *JWS) Algorithm() string {
+	return jws.headerString("alg")
+}
+
+func (jws *JWS) Nonce() string {
+	return jws.headerString("nonce")
+}
+
+func (jws *JWS) URL() string {
+	return jws.headerString("url")
+}
+
+func (jws *JWS) KeyID() string {
+	return jws.headerString("kid")
+}
+
+// JWK returns the key embedded in the protected header, or nil if the JWS uses a kid
+func (jws *JWS) JWK() (*JWK, error) {
+	raw, ok := jws.Header["jwk"]
+	if !ok {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWK(encoded)
+}
+
+// IsPostAsGet reports whether the JWS has an empty payload (RFC 8555, section 6.3)
+func (jws *JWS) IsPostAsGet() bool {
+	return jws.payload == ""
+}
+
+// UnmarshalPayload decodes the JSON payload into v
+func (jws *JWS) UnmarshalPayload(v interface{}) error {
+	if jws.IsPostAsGet() {
+		return errors.New("JWS has an empty payload")
+	}
+	return json.Unmarshal(jws.Payload, v)
+}
+
+// Verify checks the signature of the JWS. If key is nil, the jwk from the
+// protected header is used, which is only valid for newAccount and revokeCert requests.
+func (jws *JWS) Verify(key crypto.PublicKey) error {
+	if key == nil {
+		jwk, err := jws.JWK()
+		if err != nil {
+			return err
+		}
+		if jwk == nil {
+			return errors.New("No key given and JWS has no jwk")
+		}
+		if key, err = jwk.PublicKey(); err != nil {
+			return err
+		}
+	}
+
+	alg := jws.Algorithm()
+	signingInput := []byte(jws.protected + "." + jws.payload)
+	hash := hashForAlgorithm(alg)
+
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		expected, err := ecAlgorithm(key.Curve)
+		if err != nil {
+			return err
+		}
+		if alg != expected {
+			return fmt.Errorf("alg %s does not match key, expected %s", alg, expected)
+		}
+		size := coordinateSize(key.Curve)
+		if len(jws.Signature) != 2*size {
+			return fmt.Errorf("Signature must be %d bytes long for %s", 2*size, alg)
+		}
+		h := hash.New()
+		h.Write(signingInput)
+		r := new(big.Int).SetBytes(jws.Signature[:size])
+		s := new(big.Int).SetBytes(jws.Signature[size:])
+		if !ecdsa.Verify(key, h.Sum(nil), r, s) {
+			return errors.New("Invalid signature")
+		}
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			return fmt.Errorf("alg %s does not match key, expected RS256", alg)
+		}
+		h := hash.New()
+		h.Write(signingInput)
+		if err := rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), jws.Signature); err != nil {
+			return errors.New("Invalid signature")
+		}
+	case ed25519.PublicKey:
+		if alg != "EdDSA" {
+			return fmt.Errorf("alg %s does not match key, expected EdDSA", alg)
+		}
+		if !ed25519.Verify(key, signingInput, jws.Signature) {
+			return errors.New("Invalid signature")
+		}
+	default:
+		return fmt.Errorf("Unsupported key type %T", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestSignParseVerify(t *testing.T) {
+	tests := []struct {
+		alg      string
+		generate func() (crypto.Signer, error)
+	}{
+		{"RS256", func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 2048) }},
+		{"ES256", func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P256(), rand.Reader) }},
+		{"ES384", func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P384(), rand.Reader) }},
+		{"ES512", func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P521(), rand.Reader) }},
+		{"EdDSA", func() (crypto.Signer, error) {
+			_, key, err := ed25519.GenerateKey(rand.Reader)
+			return key, err
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.alg, func(t *testing.T) {
+			key, err := test.generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := test.generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			jwk, err := GetJWK(key.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jwt := JWT{
+				Header: map[string]interface{}{
+					"jwk": jwk,
+					"url": "https://example.com/acme/new-account",
+				},
+				Payload: map[string]interface{}{"termsOfServiceAgreed": true},
+			}
+			signed, err := jwt.CreateSignedPayload(key, "nonce")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jws, err := Parse(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if jws.Algorithm() != test.alg {
+				t.Errorf("alg = %s, want %s", jws.Algorithm(), test.alg)
+			}
+			if jws.Nonce() != "nonce" || jws.URL() != "https://example.com/acme/new-account" {
+				t.Errorf("unexpected header %v", jws.Header)
+			}
+			var payload map[string]interface{}
+			if err := jws.UnmarshalPayload(&payload); err != nil || payload["termsOfServiceAgreed"] != true {
+				t.Errorf("payload = %v, %v", payload, err)
+			}
+
+			// the embedded jwk and the key itself both verify
+			if err := jws.Verify(nil); err != nil {
+				t.Errorf("verify with embedded jwk: %v", err)
+			}
+			if err := jws.Verify(key.Public()); err != nil {
+				t.Errorf("verify with key: %v", err)
+			}
+			if err := jws.Verify(other.Public()); err == nil {
+				t.Error("signature verified with another key")
+			}
+
+			jws.Signature[len(jws.Signature)-1] ^= 1
+			if err := jws.Verify(key.Public()); err == nil {
+				t.Error("tampered signature verified")
+			}
+		})
+	}
+}
+
+// flattened turns the parts of a compact JWS into the flattened JSON form Parse reads
+func flattened(protected, payload, signature string) []byte {
+	data, _ := json.Marshal(JWTDTO{Protected: protected, Payload: payload, Signature: signature})
+	return data
+}
+
+func TestVerifyRFC8037Example(t *testing.T) {
+	// RFC 8037, appendix A.4. It is no ACME request, so it is put together by hand instead of parsed.
+	signature, err := base64.RawURLEncoding.DecodeString("hgyY0il_MGCjP0JzlnLWG1PPOt7-09PGcvMg3AIbQR6dWbhijcNR4ki4iylGjg5BhVsPt9g7sVvpAr_MuM0KAg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws := &JWS{
+		Header:    map[string]interface{}{"alg": "EdDSA"},
+		Signature: signature,
+		protected: "eyJhbGciOiJFZERTQSJ9",
+		payload:   "RXhhbXBsZSBvZiBFZDI1NTE5IHNpZ25pbmc",
+	}
+	key, err := rfc8037Key.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jws.Verify(key); err != nil {
+		t.Error(err)
+	}
+
+	jws.payload = "RXhhbXBsZSBvZiBFZDI1NTE5IHNpZ25pbmc="
+	if err := jws.Verify(key); err == nil {
+		t.Error("signature verified for a different payload")
+	}
+}
+
+func header(json string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(json))
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"post-as-get", string(flattened(header(`{"alg":"ES256","kid":"k","nonce":"n","url":"u"}`), "", "AAAA")), false},
+		{"jwk and kid", string(flattened(header(`{"alg":"ES256","jwk":{},"kid":"k","nonce":"n","url":"u"}`), "", "AAAA")), true},
+		{"no nonce", string(flattened(header(`{"alg":"ES256","kid":"k","url":"u"}`), "", "AAAA")), true},
+		{"no url", string(flattened(header(`{"alg":"ES256","kid":"k","nonce":"n"}`), "", "AAAA")), true},
+		{"hmac alg", string(flattened(header(`{"alg":"HS256","kid":"k","nonce":"n","url":"u"}`), "", "AAAA")), true},
+		{"not json", "not a jws", true},
+		{"invalid header", string(flattened("!!!", "", "AAAA")), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			jws, err := Parse([]byte(test.data))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error %v", err, test.wantErr)
+			}
+			if err == nil && !jws.IsPostAsGet() {
+				t.Error("empty payload is not POST-as-GET")
+			}
+		})
+	}
+}
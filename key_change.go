@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/komplexon3/acme-client/jose"
+	"github.com/komplexon3/acme-client/keys"
+)
+
+func (acme *acmeClient) rolloverAccountKey(ctx context.Context, newKey crypto.Signer) error {
+	/*
+	   POST /acme/key-change HTTP/1.1
+	   Host: example.com
+	   Content-Type: application/jose+json
+
+	   {
+	     "protected": base64url({
+	       "alg": "ES256",
+	       "kid": "https://example.com/acme/acct/evOfKhNU60wg",
+	       "nonce": "S9XaOcxP5McpnTcWPIhYuB",
+	       "url": "https://example.com/acme/key-change"
+	     }),
+	     "payload": base64url({
+	       "protected": base64url({
+	         "alg": "ES256",
+	         "jwk": { new key },
+	         "url": "https://example.com/acme/key-change"
+	       }),
+	       "payload": base64url({
+	         "account": "https://example.com/acme/acct/evOfKhNU60wg",
+	         "oldKey": { old key }
+	       }),
+	       "signature": "Xe8B94RD30Azj2ea...8BmZIRtcSKPSd8gU"
+	     }),
+	     "signature": "5TWiqIYQfIDfALQv...x9C2mg8JGPxl5bI4"
+	   }
+	*/
+	logger := acme.logger.WithField("method", "rolloverAccountKey")
+
+	if acme.endpoints.KeyChange == "" {
+		logger.Error("No key change endpoint")
+		return errors.New("KeyChange endpoint not set")
+	}
+
+	if acme.accountURL == "" {
+		logger.Error("No account URL saved. Create account before changing its key.")
+		return errors.New("Missing account URL - can't set kid")
+	}
+
+	// the new key has to be on disk before the server switches to it, otherwise
+	// a crash or a failed write loses the only key that can access the account
+	if acme.accountKeyPath == "" {
+		logger.Error("Account key not loaded from a file")
+		return errors.New("The new account key can't be stored, rerun with --account-key or --state-dir and a saved account")
+	}
+	stagedPath := acme.accountKeyPath + ".new"
+
+	oldJWK, err := jose.GetJWK(acme.privateKey.Public())
+	if err != nil {
+		return err
+	}
+	newJWK, err := jose.GetJWK(newKey.Public())
+	if err != nil {
+		return err
+	}
+
+	// the inner JWS is signed by the new key and proves that we hold it
+	inner := jose.JWT{
+		Header: map[string]interface{}{
+			"jwk": newJWK,
+			"url": acme.endpoints.KeyChange,
+		},
+		Payload: map[string]interface{}{
+			"account": acme.accountURL,
+			"oldKey":  oldJWK,
+		},
+	}
+	innerJWS, err := inner.CreateSignedPayload(newKey, "")
+	if err != nil {
+		logger.WithError(err).Error("Error signing inner JWS")
+		return err
+	}
+
+	if err := keys.Save(stagedPath, newKey); err != nil {
+		logger.WithError(err).Error("Error writing new account key")
+		return err
+	}
+
+	headers := map[string]interface{}{
+		"kid": acme.accountURL,
+	}
+
+	resp, err := acme.doJosePostRequest(ctx, acme.endpoints.KeyChange, headers, json.RawMessage(innerJWS))
+	if err != nil {
+		// the server may have switched keys before the connection broke
+		logger.WithField("key", stagedPath).Error("Error changing account key, the new key is kept in case the server accepted it: ", err)
+		return err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.WithField("key", stagedPath).Error("Error reading response body, the new key is kept in case the server accepted it: ", err)
+		return err
+	}
+
+	if err := checkResponse(resp, body, 200); err != nil {
+		logger.WithError(err).Error("Error changing account key")
+		os.Remove(stagedPath)
+		return err
+	}
+
+	// the server only accepts the new key from now on
+	if err := os.Rename(stagedPath, acme.accountKeyPath); err != nil {
+		logger.WithField("key", stagedPath).Error("Error replacing the old account key, the new key is kept: ", err)
+		return err
+	}
+	acme.privateKey = newKey
+	return acme.saveAccount()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/komplexon3/acme-client/jose"
+	"github.com/komplexon3/acme-client/keys"
+)
+
+// checkKeyChange checks a keyChange request as a CA would (RFC 8555, section 7.3.5)
+func checkKeyChange(r *http.Request, accountURL string, oldKey, newKey crypto.PublicKey) error {
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return err
+	}
+	outer, err := jose.Parse(body)
+	if err != nil {
+		return err
+	}
+	if outer.KeyID() != accountURL {
+		return fmt.Errorf("outer kid = %s, want %s", outer.KeyID(), accountURL)
+	}
+	if err := outer.Verify(oldKey); err != nil {
+		return fmt.Errorf("outer JWS is not signed by the old key: %v", err)
+	}
+
+	// the inner JWS has no nonce, so jose.Parse does not take it
+	var inner jose.JWTDTO
+	if err := outer.UnmarshalPayload(&inner); err != nil {
+		return err
+	}
+	var header map[string]json.RawMessage
+	if err := decodeJSONSegment(inner.Protected, &header); err != nil {
+		return err
+	}
+	if _, ok := header["kid"]; ok {
+		return fmt.Errorf("inner header has a kid")
+	}
+	if _, ok := header["nonce"]; ok {
+		return fmt.Errorf("inner header has a nonce")
+	}
+	var url string
+	if err := json.Unmarshal(header["url"], &url); err != nil || url != outer.URL() {
+		return fmt.Errorf("inner url = %s, want %s", url, outer.URL())
+	}
+	jwk, err := jose.ParseJWK(header["jwk"])
+	if err != nil {
+		return fmt.Errorf("inner jwk: %v", err)
+	}
+	if key, err := jwk.PublicKey(); err != nil || !newKey.(*ecdsa.PublicKey).Equal(key) {
+		return fmt.Errorf("inner jwk is not the new key")
+	}
+	if err := verifyES256(newKey.(*ecdsa.PublicKey), inner); err != nil {
+		return fmt.Errorf("inner JWS is not signed by the new key: %v", err)
+	}
+
+	var payload struct {
+		Account string          `json:"account"`
+		OldKey  json.RawMessage `json:"oldKey"`
+	}
+	if err := decodeJSONSegment(inner.Payload, &payload); err != nil {
+		return err
+	}
+	if payload.Account != accountURL {
+		return fmt.Errorf("inner account = %s, want %s", payload.Account, accountURL)
+	}
+	oldJWK, err := jose.ParseJWK(payload.OldKey)
+	if err != nil {
+		return fmt.Errorf("inner oldKey: %v", err)
+	}
+	if key, err := oldJWK.PublicKey(); err != nil || !oldKey.(*ecdsa.PublicKey).Equal(key) {
+		return fmt.Errorf("inner oldKey is not the old key")
+	}
+	return nil
+}
+
+func decodeJSONSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func verifyES256(key *ecdsa.PublicKey, jws jose.JWTDTO) error {
+	signature, err := base64.RawURLEncoding.DecodeString(jws.Signature)
+	if err != nil {
+		return err
+	}
+	if len(signature) != 64 {
+		return fmt.Errorf("signature has %d bytes", len(signature))
+	}
+	digest := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+func TestRolloverAccountKey(t *testing.T) {
+	tests := []struct {
+		name string
+		// respond answers the keyChange request after it was checked
+		respond     func(w http.ResponseWriter)
+		wantErr     bool
+		wantNewKey  bool
+		wantStaged  bool
+		wantRenamed bool
+	}{
+		{
+			name:        "accepted",
+			respond:     func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+			wantNewKey:  true,
+			wantRenamed: true,
+		},
+		{
+			name: "problem",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"type": "urn:ietf:params:acme:error:malformed", "detail": "key in use"}`)
+			},
+			wantErr: true,
+		},
+		{
+			// the server may have switched keys before the connection broke
+			name:       "connection lost",
+			respond:    func(http.ResponseWriter) { panic(http.ErrAbortHandler) },
+			wantErr:    true,
+			wantStaged: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var acmeClient *acmeClient
+			var oldKey crypto.Signer
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := checkKeyChange(r, acmeClient.accountURL, oldKey.Public(), newKey.Public()); err != nil {
+					t.Error(err)
+				}
+				test.respond(w)
+			}))
+			defer server.Close()
+
+			acmeClient = testClient(t, server)
+			acmeClient.maxAttempts = 1
+			acmeClient.endpoints.KeyChange = server.URL + "/key-change"
+			oldKey = acmeClient.privateKey
+
+			acmeClient.accountKeyPath = filepath.Join(t.TempDir(), "account.key")
+			stagedPath := acmeClient.accountKeyPath + ".new"
+			if err := keys.Save(acmeClient.accountKeyPath, oldKey); err != nil {
+				t.Fatal(err)
+			}
+
+			err = acmeClient.rolloverAccountKey(context.Background(), newKey)
+			if test.wantErr && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !test.wantErr && err != nil {
+				t.Fatal(err)
+			}
+
+			wantKey := oldKey
+			if test.wantNewKey {
+				wantKey = newKey
+			}
+			if acmeClient.privateKey != wantKey {
+				t.Error("client does not use the expected account key")
+			}
+
+			saved, err := keys.Load(acmeClient.accountKeyPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			savedKey := oldKey
+			if test.wantRenamed {
+				savedKey = newKey
+			}
+			if !saved.(*ecdsa.PrivateKey).Equal(savedKey) {
+				t.Errorf("%s does not hold the expected key", acmeClient.accountKeyPath)
+			}
+
+			staged, err := keys.Load(stagedPath)
+			switch {
+			case test.wantStaged && err != nil:
+				t.Errorf("new key was not kept: %v", err)
+			case test.wantStaged && !staged.(*ecdsa.PrivateKey).Equal(newKey):
+				t.Errorf("%s does not hold the new key", stagedPath)
+			case !test.wantStaged && !os.IsNotExist(err):
+				t.Errorf("%s was not removed: %v", stagedPath, err)
+			}
+		})
+	}
+}
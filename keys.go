@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/komplexon3/acme-client/keys"
+)
+
+type KeyType string
+
+const (
+	P256    KeyType = "p256"
+	P384    KeyType = "p384"
+	P521    KeyType = "p521"
+	RSA2048 KeyType = "rsa2048"
+	RSA4096 KeyType = "rsa4096"
+	ED25519 KeyType = "ed25519"
+)
+
+func generateKey(keyType KeyType) (crypto.Signer, error) {
+	switch keyType {
+	case P256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case P384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case P521:
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case RSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case RSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case ED25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("Unsupported key type %s", keyType)
+	}
+}
+
+// loadOrGenerateKey loads the key stored at path. If path is empty a fresh key
+// is generated, if the file does not exist yet the fresh key is written to it.
+func loadOrGenerateKey(path string, keyType KeyType) (crypto.Signer, error) {
+	if path == "" {
+		return generateKey(keyType)
+	}
+
+	key, err := keys.Load(path)
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	key, err = generateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+	if err := keys.Save(path, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,130 @@
+// Package keys reads and writes private keys for accounts and certificates.
+package keys
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/komplexon3/acme-client/jose"
+)
+
+// Parse decodes a private key in PEM (PKCS#8, SEC1 or PKCS#1) or JWK JSON form
+func Parse(data []byte) (crypto.Signer, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return jose.ParsePrivateJWK(trimmed)
+	}
+
+	// skip blocks that don't hold a key, e.g. the "EC PARAMETERS" block openssl writes in front
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("No private key found in PEM or JWK form")
+		}
+
+		var key interface{}
+		var err error
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		// "ECDSA PRIVATE KEY" was written by earlier versions of the client
+		case "EC PRIVATE KEY", "ECDSA PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("Unsupported key type %T", key)
+		}
+		return signer, nil
+	}
+}
+
+// Load reads a private key from path, see Parse for the supported formats
+func Load(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %v", path, err)
+	}
+	return key, nil
+}
+
+// Exportable reports whether the key is held in memory and can be written to disk
+func Exportable(key crypto.Signer) bool {
+	switch key.(type) {
+	case *ecdsa.PrivateKey, *rsa.PrivateKey, ed25519.PrivateKey:
+		return true
+	default:
+		return false
+	}
+}
+
+// MarshalPEM encodes key as a PKCS#8 "PRIVATE KEY" PEM block
+func MarshalPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: der,
+	}), nil
+}
+
+// Save writes key to path as PKCS#8 PEM, readable only by the owner
+func Save(path string, key crypto.Signer) error {
+	data, err := MarshalPEM(key)
+	if err != nil {
+		return err
+	}
+	return WriteFile(path, data)
+}
+
+// WriteFile atomically replaces path with data, readable only by the owner.
+// The data goes to a temporary file created with mode 0600 that is renamed over
+// path, so the key is never readable by others and never half written.
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -1,32 +1,41 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
 )
 
-func (acme *acmeClient) fetchNewNote() error {
+func (acme *acmeClient) fetchNewNote(ctx context.Context) error {
 	logger := acme.logger.WithField("method", "FetchNewNote")
 	if acme.endpoints.NewNonce == "" {
 		return errors.New("NewNonce endpoint not set")
 	}
 
-	req, err := http.NewRequest("HEAD", acme.endpoints.NewNonce, nil)
+	resp, err := acme.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "HEAD", acme.endpoints.NewNonce, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		// nonce could be cached leading to us getting badNonce errors
+		req.Header.Add("Cache-Control", "no-store")
+		return req, nil
+	}, true)
 	if err != nil {
-		logger.WithError(err).Error("Error creating request")
+		logger.WithError(err).Error("Error fetching nonce")
 		return err
 	}
-
-	// nonce could be cached leading to us getting badNonce errors
-	req.Header.Add("Cache-Control", "no-store")
-
-	resp, err := acme.httpClient.Do(req)
+	resp.Body.Close()
 
 	if resp.StatusCode != 200 {
 		return errors.New("NewNonce endpoint returned " + resp.Status)
 	}
 
-	acme.currentNonce = resp.Header.Get("Replay-Nonce")
+	// doWithRetry already put the Replay-Nonce into the pool
+	if resp.Header.Get("Replay-Nonce") == "" {
+		return errors.New("NewNonce endpoint returned no nonce")
+	}
 
 	return nil
 }
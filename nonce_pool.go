@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// noncePoolSize is how many nonces are kept at most, older ones are dropped first
+	noncePoolSize = 16
+	// noncePrefetchTimeout bounds a background prefetch, it is not tied to any request
+	noncePrefetchTimeout = 10 * time.Second
+)
+
+// noncePool collects the nonces of all responses so that requests can be sent
+// concurrently. Each nonce is handed out once.
+type noncePool struct {
+	mu          sync.Mutex
+	nonces      []string
+	waiting     int
+	prefetching bool
+	background  bool
+	fetch       func(ctx context.Context) error
+	logger      *logrus.Entry
+}
+
+// newNoncePool creates an empty pool. fetch asks the server for a new nonce
+// and is expected to Put it into the pool. Every response brings a new nonce,
+// so a caller only has to fetch one if the pool is empty. With background set,
+// callers that find the pool empty at the same time also prefetch nonces for
+// the requests that follow them; a single caller never causes more requests
+// than it needs. Without background, nonces are only fetched on demand, which
+// keeps the sequence of requests deterministic.
+func newNoncePool(logger *logrus.Entry, fetch func(ctx context.Context) error, background bool) *noncePool {
+	return &noncePool{
+		fetch:      fetch,
+		background: background,
+		logger:     logger.WithField("component", "noncePool"),
+	}
+}
+
+// Put adds a nonce harvested from a response
+func (pool *noncePool) Put(nonce string) {
+	if nonce == "" {
+		return
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.nonces = append(pool.nonces, nonce)
+	if len(pool.nonces) > noncePoolSize {
+		pool.nonces = pool.nonces[len(pool.nonces)-noncePoolSize:]
+	}
+}
+
+// Get hands out the newest nonce, fetching one if the pool is empty
+func (pool *noncePool) Get(ctx context.Context) (string, error) {
+	for {
+		if nonce, ok := pool.take(); ok {
+			return nonce, nil
+		}
+		// another request may take the fetched nonce first, then we simply fetch again
+		pool.startWaiting()
+		err := pool.fetch(ctx)
+		pool.stopWaiting()
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// Clear drops all nonces, the server rejected one of them so the others are likely stale too
+func (pool *noncePool) Clear() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.nonces = nil
+}
+
+// Len returns how many nonces are available
+func (pool *noncePool) Len() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	return len(pool.nonces)
+}
+
+func (pool *noncePool) take() (string, bool) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if len(pool.nonces) == 0 {
+		return "", false
+	}
+	nonce := pool.nonces[len(pool.nonces)-1]
+	pool.nonces = pool.nonces[:len(pool.nonces)-1]
+	return nonce, true
+}
+
+// startWaiting registers a caller that fetches a nonce because the pool is empty.
+// If others are already waiting, the requests are concurrent and nonces for as
+// many callers are prefetched in the background.
+func (pool *noncePool) startWaiting() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.waiting++
+	if pool.background && pool.waiting > 1 && !pool.prefetching {
+		pool.prefetching = true
+		go pool.prefetch(pool.waiting)
+	}
+}
+
+func (pool *noncePool) stopWaiting() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.waiting--
+}
+
+// prefetch fetches up to target nonces in the background, stopping early once the pool holds target nonces
+func (pool *noncePool) prefetch(target int) {
+	defer func() {
+		pool.mu.Lock()
+		pool.prefetching = false
+		pool.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), noncePrefetchTimeout)
+	defer cancel()
+
+	for i := 0; i < target && pool.Len() < target; i++ {
+		if err := pool.fetch(ctx); err != nil {
+			pool.logger.WithError(err).Debug("Error prefetching nonce")
+			return
+		}
+	}
+}
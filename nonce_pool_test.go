@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNoncePool(t *testing.T) {
+	tests := []struct {
+		name    string
+		put     []string
+		clear   bool
+		want    []string
+		fetched int
+	}{
+		{"newest first", []string{"a", "b", "c"}, false, []string{"c", "b", "a"}, 0},
+		{"empty nonces are ignored", []string{"a", ""}, false, []string{"a"}, 0},
+		{"fetch when empty", nil, false, []string{"fetched-1", "fetched-2"}, 2},
+		{"clear", []string{"a", "b"}, true, []string{"fetched-1"}, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var pool *noncePool
+			fetched := 0
+			pool = newNoncePool(discardLogger(), func(context.Context) error {
+				fetched++
+				pool.Put(fmt.Sprintf("fetched-%d", fetched))
+				return nil
+			}, false)
+
+			for _, nonce := range test.put {
+				pool.Put(nonce)
+			}
+			if test.clear {
+				pool.Clear()
+			}
+			for _, want := range test.want {
+				nonce, err := pool.Get(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if nonce != want {
+					t.Errorf("Get = %s, want %s", nonce, want)
+				}
+			}
+			if fetched != test.fetched {
+				t.Errorf("fetched %d nonces, want %d", fetched, test.fetched)
+			}
+		})
+	}
+}
+
+func TestNoncePoolSize(t *testing.T) {
+	pool := newNoncePool(discardLogger(), nil, false)
+	for i := 0; i < noncePoolSize+4; i++ {
+		pool.Put(fmt.Sprint(i))
+	}
+	if pool.Len() != noncePoolSize {
+		t.Fatalf("pool holds %d nonces, want %d", pool.Len(), noncePoolSize)
+	}
+	// the oldest nonces were dropped
+	for i := noncePoolSize + 3; i >= 4; i-- {
+		if nonce, _ := pool.take(); nonce != fmt.Sprint(i) {
+			t.Fatalf("take = %s, want %d", nonce, i)
+		}
+	}
+}
+
+func TestNoncePoolFetchError(t *testing.T) {
+	errFetch := errors.New("no nonce")
+	pool := newNoncePool(discardLogger(), func(context.Context) error { return errFetch }, false)
+	if _, err := pool.Get(context.Background()); !errors.Is(err, errFetch) {
+		t.Fatalf("Get error = %v, want %v", err, errFetch)
+	}
+}
+
+func TestNoncePoolPrefetch(t *testing.T) {
+	tests := []struct {
+		name       string
+		background bool
+		callers    int
+		// the nonces fetched in total, including prefetched ones
+		wantMin, wantMax int32
+	}{
+		{"single caller", false, 1, 1, 1},
+		// sequential requests get their next nonce from the previous response
+		{"single caller, background", true, 1, 1, 1},
+		{"concurrent callers", false, 2, 2, 2},
+		{"concurrent callers, background", true, 2, 3, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var pool *noncePool
+			var fetched, blocked int32
+			release := make(chan struct{})
+			pool = newNoncePool(discardLogger(), func(context.Context) error {
+				atomic.AddInt32(&blocked, 1)
+				<-release
+				pool.Put(fmt.Sprint("fetched-", atomic.AddInt32(&fetched, 1)))
+				return nil
+			}, test.background)
+
+			var wg sync.WaitGroup
+			for i := 0; i < test.callers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := pool.Get(context.Background()); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			// let the fetches go once all callers, and the prefetch if any, wait for them
+			waitFor(t, func() bool { return atomic.LoadInt32(&blocked) >= int32(test.callers) })
+			if test.wantMin > int32(test.callers) {
+				waitFor(t, func() bool { return atomic.LoadInt32(&blocked) > int32(test.callers) })
+			}
+			close(release)
+			wg.Wait()
+			waitFor(t, func() bool { return !pool.isPrefetching() })
+
+			if n := atomic.LoadInt32(&fetched); n < test.wantMin || n > test.wantMax {
+				t.Errorf("fetched %d nonces, want %d to %d", n, test.wantMin, test.wantMax)
+			}
+		})
+	}
+}
+
+func (pool *noncePool) isPrefetching() bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.prefetching
+}
+
+// waitFor polls condition for up to a second
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNoncePoolConcurrent(t *testing.T) {
+	var pool *noncePool
+	var fetched int32
+	pool = newNoncePool(discardLogger(), func(context.Context) error {
+		pool.Put(fmt.Sprint("fetched-", atomic.AddInt32(&fetched, 1)))
+		return nil
+	}, true)
+
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nonce, err := pool.Get(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[nonce] {
+				t.Errorf("nonce %s was handed out twice", nonce)
+			}
+			seen[nonce] = true
+		}()
+	}
+	wg.Wait()
+}
@@ -1,7 +1,8 @@
 package main
 
 import (
-	"crypto/ecdsa"
+	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/x509"
 	"encoding/base64"
@@ -9,6 +10,9 @@ import (
 	"errors"
 	"io"
 	"time"
+
+	"github.com/komplexon3/acme-client/acme"
+	"github.com/komplexon3/acme-client/state"
 )
 
 type identifier struct {
@@ -27,17 +31,20 @@ type Order struct {
 
 type orderPayload struct {
 	Identifiers []identifier `json:"identifiers"`
+	// Replaces is the ARI identifier of the certificate this order renews
+	Replaces string `json:"replaces,omitempty"`
 }
 
 type orderMsg struct {
-	Status        string       `json:"status"`
-	Identifiers   []identifier `json:"identifiers"`
-	Authorization []string     `json:"authorizations"`
-	Finalize      string       `json:"finalize"`
-	Certificate   string       `json:"certificate"`
+	Status        string        `json:"status"`
+	Identifiers   []identifier  `json:"identifiers"`
+	Authorization []string      `json:"authorizations"`
+	Finalize      string        `json:"finalize"`
+	Certificate   string        `json:"certificate"`
+	Error         *acme.Problem `json:"error,omitempty"`
 }
 
-func (acme *acmeClient) createOrder(domains []string) (*Order, error) {
+func (acme *acmeClient) createOrder(ctx context.Context, domains []string, replaces string) (*Order, error) {
 	logger := acme.logger.WithField("method", "createAccount")
 	if acme.endpoints.NewOrder == "" {
 		logger.Error("No new order endpoint")
@@ -60,6 +67,7 @@ func (acme *acmeClient) createOrder(domains []string) (*Order, error) {
 
 	payload := orderPayload{
 		Identifiers: identifiers,
+		Replaces:    replaces,
 	}
 	headers := map[string]interface{}{
 		"kid": acme.accountURL,
@@ -67,7 +75,7 @@ func (acme *acmeClient) createOrder(domains []string) (*Order, error) {
 
 	logger.WithField("payload", payload).Info("Creating order for domains: ", domains)
 
-	resp, err := acme.doJosePostRequest(acme.endpoints.NewOrder, headers, payload)
+	resp, err := acme.doJosePostRequest(ctx, acme.endpoints.NewOrder, headers, payload)
 	if err != nil {
 		logger.Error("Error creating order: ", err)
 		return nil, err
@@ -79,9 +87,9 @@ func (acme *acmeClient) createOrder(domains []string) (*Order, error) {
 		return nil, err
 	}
 
-	if resp.StatusCode != 201 {
-		logger.WithField("ErrorDesc", getErrorDetails(string(body))).Error("Error creating order: ", resp.Status)
-		return nil, errors.New("Error creating order: " + resp.Status)
+	if err := checkResponse(resp, body, 201); err != nil {
+		logger.WithError(err).Error("Error creating order")
+		return nil, err
 	}
 
 	var orderResponse orderMsg
@@ -103,11 +111,12 @@ func (acme *acmeClient) createOrder(domains []string) (*Order, error) {
 		finalizeURL:    orderResponse.Finalize,
 		identifiers:    orderResponse.Identifiers,
 	}
+	acme.saveOrder(&order)
 
 	return &order, nil
 }
 
-func (acme *acmeClient) finalizeOrder(order *Order, key *ecdsa.PrivateKey) error {
+func (acme *acmeClient) finalizeOrder(ctx context.Context, order *Order, key crypto.Signer) error {
 	logger := acme.logger.WithField("method", "finalizeOrder")
 
 	if order.finalizeURL == "" {
@@ -144,18 +153,23 @@ func (acme *acmeClient) finalizeOrder(order *Order, key *ecdsa.PrivateKey) error
 		"csr": csrEncoded,
 	}
 
-	resp, err := acme.doJosePostRequest(order.finalizeURL, headers, payload)
+	resp, err := acme.doJosePostRequest(ctx, order.finalizeURL, headers, payload)
 	if err != nil {
 		logger.Error("Error finalizing order: ", err)
 		return err
 	}
 
-	body, err1 := io.ReadAll(resp.Body)
-	if err1 != nil {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
 		logger.Error("Error reading response body: ", err)
 		return err
 	}
 
+	if err := checkResponse(resp, body, 200); err != nil {
+		logger.WithError(err).Error("Error finalizing order")
+		return err
+	}
+
 	var orderResponse orderMsg
 	if err := json.Unmarshal(body, &orderResponse); err != nil {
 		logger.WithError(err).Error("Error unmarshalling order response")
@@ -163,11 +177,12 @@ func (acme *acmeClient) finalizeOrder(order *Order, key *ecdsa.PrivateKey) error
 	}
 
 	order.status = orderResponse.Status
+	acme.saveOrder(order)
 
 	return nil
 }
 
-func (acme *acmeClient) pollUntilReady(order *Order, maxRetries int) error {
+func (acme *acmeClient) pollUntilReady(ctx context.Context, order *Order, maxRetries int) error {
 	logger := acme.logger.WithField("method", "poll until ready")
 
 	if order.orderURL == "" {
@@ -185,7 +200,7 @@ func (acme *acmeClient) pollUntilReady(order *Order, maxRetries int) error {
 	}
 
 	for i := 0; i < maxRetries; i++ {
-		resp, err := acme.doJosePostRequest(order.orderURL, headers, nil)
+		resp, err := acme.doJosePostRequest(ctx, order.orderURL, headers, nil)
 		if err != nil {
 			logger.Error("Error polling order: ", err)
 			return err
@@ -197,6 +212,11 @@ func (acme *acmeClient) pollUntilReady(order *Order, maxRetries int) error {
 			return err
 		}
 
+		if err := checkResponse(resp, body, 200); err != nil {
+			logger.WithError(err).Error("Error polling order")
+			return err
+		}
+
 		var orderResponse orderMsg
 		if err := json.Unmarshal(body, &orderResponse); err != nil {
 			logger.WithError(err).Error("Error unmarshalling order response")
@@ -207,14 +227,57 @@ func (acme *acmeClient) pollUntilReady(order *Order, maxRetries int) error {
 			// the server verified that the authorization is valid
 			order.status = orderResponse.Status
 			order.certificateURL = orderResponse.Certificate
+			acme.saveOrder(order)
 			return nil
 		}
 
+		// an invalid order carries the problem that made it fail
+		if orderResponse.Status == "invalid" && orderResponse.Error != nil {
+			logger.WithError(orderResponse.Error).Error("Order is invalid")
+			return orderResponse.Error
+		}
+
 		if orderResponse.Status != "processing" {
 			return errors.New("Order is not processing. Status: " + orderResponse.Status)
 		}
-		time.Sleep(time.Second)
+		if err := acme.wait(ctx, time.Second); err != nil {
+			return err
+		}
 	}
 	logger.Error("Max retries reached. Order not ready.")
 	return errors.New("Max retries reached. Order not ready.")
 }
+
+func stateIdentifiers(identifiers []identifier) []state.Identifier {
+	converted := make([]state.Identifier, len(identifiers))
+	for i, identifier := range identifiers {
+		converted[i] = state.Identifier{Type: identifier.Type, Value: identifier.Value}
+	}
+	return converted
+}
+
+// saveOrder records the order in the state directory, if one is configured
+func (acme *acmeClient) saveOrder(order *Order) {
+	if acme.state == nil {
+		return
+	}
+
+	authorizations := make([]string, len(order.authorizations))
+	for i, auth := range order.authorizations {
+		authorizations[i] = auth.authorizationURL
+	}
+
+	err := acme.state.SaveOrder(&state.Order{
+		URL:            order.orderURL,
+		Account:        acme.accountURL,
+		Status:         order.status,
+		Identifiers:    stateIdentifiers(order.identifiers),
+		Authorizations: authorizations,
+		Finalize:       order.finalizeURL,
+		Certificate:    order.certificateURL,
+	})
+	if err != nil {
+		// losing the record of an order is no reason to abort the issuance
+		acme.logger.WithError(err).Warn("Error saving order")
+	}
+}
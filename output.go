@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/komplexon3/acme-client/keys"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Additional output formats, written next to cert.pem, chain.pem, fullchain.pem and key.pem
+const (
+	// OutputPKCS12 writes a password protected keystore with key and chain to cert.p12
+	OutputPKCS12 = "pkcs12"
+	// OutputDER writes the end-entity certificate DER encoded to cert.der
+	OutputDER = "der"
+	// OutputCombined writes the full chain followed by the key to combined.pem, as HAProxy expects it
+	OutputCombined = "combined"
+)
+
+type outputOptions struct {
+	formats []string
+	// the PKCS#12 password is read from pkcs12PasswordFile, or else from the environment variable pkcs12PasswordEnv
+	pkcs12PasswordFile string
+	pkcs12PasswordEnv  string
+	// pkcs12Password is filled in by checkOutputOptions
+	pkcs12Password string
+}
+
+// checkOutputOptions makes sure that every output can be written before an
+// order is placed, exportable tells whether the certificate key can leave memory.
+// It reads the PKCS#12 password into options.
+func checkOutputOptions(options *outputOptions, exportable bool) error {
+	for _, format := range options.formats {
+		switch format {
+		case OutputPKCS12, OutputCombined:
+			if !exportable {
+				return fmt.Errorf("The %s output contains the certificate key, which is held by an external signer", format)
+			}
+		}
+		if format == OutputPKCS12 {
+			password, err := pkcs12Password(*options)
+			if err != nil {
+				return err
+			}
+			options.pkcs12Password = password
+		}
+	}
+	return nil
+}
+
+// writeOutputs writes the certificate in every additional format of options
+func writeOutputs(options outputOptions, certs []*x509.Certificate, key crypto.Signer) error {
+	for _, format := range options.formats {
+		var err error
+		switch format {
+		case OutputPKCS12:
+			err = writePKCS12(options, certs, key)
+		case OutputDER:
+			err = os.WriteFile("cert.der", certs[0].Raw, 0644)
+		case OutputCombined:
+			err = writeCombined(certs, key)
+		default:
+			err = fmt.Errorf("Unsupported output format %s", format)
+		}
+		if err != nil {
+			return fmt.Errorf("Error writing %s output: %v", format, err)
+		}
+	}
+	return nil
+}
+
+func writePKCS12(options outputOptions, certs []*x509.Certificate, key crypto.Signer) error {
+	if !keys.Exportable(key) {
+		return errors.New("The key is held by an external signer and can't be put into a keystore")
+	}
+
+	data, err := pkcs12.Modern.Encode(key, certs[0], certs[1:], options.pkcs12Password)
+	if err != nil {
+		return err
+	}
+	return keys.WriteFile("cert.p12", data)
+}
+
+func pkcs12Password(options outputOptions) (string, error) {
+	if options.pkcs12PasswordFile != "" {
+		data, err := os.ReadFile(options.pkcs12PasswordFile)
+		if err != nil {
+			return "", err
+		}
+		password := strings.TrimRight(string(data), "\r\n")
+		if strings.TrimSpace(password) == "" {
+			return "", fmt.Errorf("Password file %s is empty", options.pkcs12PasswordFile)
+		}
+		return password, nil
+	}
+	if options.pkcs12PasswordEnv != "" {
+		password, ok := os.LookupEnv(options.pkcs12PasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("Environment variable %s is not set", options.pkcs12PasswordEnv)
+		}
+		if strings.TrimSpace(password) == "" {
+			return "", fmt.Errorf("Environment variable %s is empty", options.pkcs12PasswordEnv)
+		}
+		return password, nil
+	}
+	return "", errors.New("PKCS#12 output needs --pkcs12-password-file or --pkcs12-password-env")
+}
+
+func writeCombined(certs []*x509.Certificate, key crypto.Signer) error {
+	if !keys.Exportable(key) {
+		return errors.New("The key is held by an external signer and can't be written to disk")
+	}
+
+	encodedKey, err := keys.MarshalPEM(key)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(encodeCertificates(certs))
+	buf.Write(encodedKey)
+	return keys.WriteFile("combined.pem", buf.Bytes())
+}
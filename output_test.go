@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckOutputOptions(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("from file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(emptyFile, []byte(" \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_PKCS12_PASSWORD", "from env")
+	t.Setenv("TEST_PKCS12_EMPTY", "")
+
+	tests := []struct {
+		name         string
+		options      outputOptions
+		exportable   bool
+		wantPassword string
+		wantErr      bool
+	}{
+		{"no outputs", outputOptions{}, false, "", false},
+		{"der with signer", outputOptions{formats: []string{OutputDER}}, false, "", false},
+		{"combined with signer", outputOptions{formats: []string{OutputCombined}}, false, "", true},
+		{"pkcs12 with signer", outputOptions{formats: []string{OutputPKCS12}, pkcs12PasswordEnv: "TEST_PKCS12_PASSWORD"}, false, "", true},
+		{"pkcs12 from file", outputOptions{formats: []string{OutputPKCS12}, pkcs12PasswordFile: passwordFile}, true, "from file", false},
+		{"pkcs12 from env", outputOptions{formats: []string{OutputPKCS12}, pkcs12PasswordEnv: "TEST_PKCS12_PASSWORD"}, true, "from env", false},
+		{"pkcs12 empty env", outputOptions{formats: []string{OutputPKCS12}, pkcs12PasswordEnv: "TEST_PKCS12_EMPTY"}, true, "", true},
+		{"pkcs12 unset env", outputOptions{formats: []string{OutputPKCS12}, pkcs12PasswordEnv: "TEST_PKCS12_UNSET"}, true, "", true},
+		{"pkcs12 empty file", outputOptions{formats: []string{OutputPKCS12}, pkcs12PasswordFile: emptyFile}, true, "", true},
+		{"pkcs12 missing file", outputOptions{formats: []string{OutputPKCS12}, pkcs12PasswordFile: passwordFile + ".missing"}, true, "", true},
+		{"pkcs12 without password", outputOptions{formats: []string{OutputPKCS12}}, true, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := test.options
+			err := checkOutputOptions(&options, test.exportable)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if options.pkcs12Password != test.wantPassword {
+				t.Errorf("password = %q, want %q", options.pkcs12Password, test.wantPassword)
+			}
+		})
+	}
+}
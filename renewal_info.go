@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/komplexon3/acme-client/state"
+	"github.com/sirupsen/logrus"
+)
+
+type renewalWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// renewalInfo is the response of the renewalInfo endpoint (RFC 9773, section 4.2)
+type renewalInfo struct {
+	SuggestedWindow renewalWindow `json:"suggestedWindow"`
+	ExplanationURL  string        `json:"explanationURL,omitempty"`
+	// RetryAfter is when the client should check again, taken from the Retry-After header
+	RetryAfter time.Time `json:"-"`
+}
+
+// ariCertID computes the certificate identifier used by ARI: the key identifier
+// of the authority key identifier and the DER encoded serial, both base64url encoded
+func ariCertID(cert *x509.Certificate) (string, error) {
+	if len(cert.AuthorityKeyId) == 0 {
+		return "", errors.New("Certificate has no authority key identifier")
+	}
+
+	// the serial is encoded as DER INTEGER content, so a leading zero is needed
+	// if the most significant bit is set
+	serial := cert.SerialNumber.Bytes()
+	if len(serial) == 0 || serial[0]&0x80 != 0 {
+		serial = append([]byte{0}, serial...)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(cert.AuthorityKeyId) + "." + base64.RawURLEncoding.EncodeToString(serial), nil
+}
+
+// loadLeafCertificate reads the first certificate from a PEM chain file
+func loadLeafCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("No certificate found in " + path)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+func (acme *acmeClient) getRenewalInfo(ctx context.Context, cert *x509.Certificate, maxRetries int) (*renewalInfo, error) {
+	logger := acme.logger.WithField("method", "getRenewalInfo")
+
+	if acme.endpoints.RenewalInfo == "" {
+		logger.Error("No renewal info endpoint")
+		return nil, errors.New("RenewalInfo endpoint not set")
+	}
+
+	certID, err := ariCertID(cert)
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimRight(acme.endpoints.RenewalInfo, "/") + "/" + certID
+
+	for i := 0; i < maxRetries; i++ {
+		// renewal info is fetched with a plain GET, it doesn't need an account
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := acme.httpClient.Do(req)
+		if err != nil {
+			logger.Error("Error getting renewal info: ", err)
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			logger.Error("Error reading response body: ", err)
+			return nil, err
+		}
+
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+
+		if resp.StatusCode == 200 {
+			var info renewalInfo
+			if err := json.Unmarshal(body, &info); err != nil {
+				logger.WithError(err).Error("Error unmarshalling renewal info")
+				return nil, err
+			}
+			info.RetryAfter = retryAfter
+			return &info, nil
+		}
+
+		if (resp.StatusCode == 429 || resp.StatusCode == 503) && !retryAfter.IsZero() {
+			wait := time.Until(retryAfter)
+			if wait > maxBackoff {
+				logger.Warnf("Renewal info not available until %s, giving up", retryAfter)
+				return nil, fmt.Errorf("Renewal info not available until %s", retryAfter)
+			}
+			logger.Info("Renewal info not available, retrying at ", retryAfter)
+			if err := acme.wait(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		err = checkResponse(resp, body, 200)
+		logger.WithError(err).Error("Error getting renewal info")
+		return nil, err
+	}
+	return nil, fmt.Errorf("Renewal info not available after %d attempts", maxRetries)
+}
+
+// renewalTime picks a random point in the suggested window, as recommended by RFC 9773, section 4.2
+func (info *renewalInfo) renewalTime() time.Time {
+	window := info.SuggestedWindow
+	if !window.End.After(window.Start) {
+		return window.Start
+	}
+	return window.Start.Add(time.Duration(rand.Int63n(int64(window.End.Sub(window.Start)))))
+}
+
+// checkRenewal finds the certificate that is being renewed, either certPath or
+// the latest certificate of the lineage for domains, and asks the CA whether it
+// is due. It returns the ARI identifier to put into the order, which is empty
+// if there is nothing to replace, and whether a new certificate should be issued.
+func (acme *acmeClient) checkRenewal(ctx context.Context, domains []string, certPath string, force bool) (string, bool, error) {
+	logger := acme.logger.WithField("method", "checkRenewal")
+
+	if certPath == "" && acme.state != nil {
+		identifiers := make([]state.Identifier, len(domains))
+		for i, domain := range domains {
+			identifiers[i] = state.Identifier{Type: "dns", Value: domain}
+		}
+		lineage, err := acme.state.Lineage(identifiers)
+		if err != nil {
+			return "", false, err
+		}
+		if latest := lineage.Latest(); latest != nil {
+			certPath = lineage.Path(latest)
+		}
+	}
+
+	if certPath == "" || acme.endpoints.RenewalInfo == "" {
+		return "", true, nil
+	}
+
+	cert, err := loadLeafCertificate(certPath)
+	if err != nil {
+		return "", false, err
+	}
+	certID, err := ariCertID(cert)
+	if err != nil {
+		return "", false, err
+	}
+
+	info, err := acme.getRenewalInfo(ctx, cert, 3)
+	if err != nil {
+		return "", false, err
+	}
+
+	renewAt := info.renewalTime()
+	logger.WithFields(logrus.Fields{
+		"cert":        certPath,
+		"windowStart": info.SuggestedWindow.Start,
+		"windowEnd":   info.SuggestedWindow.End,
+		"explanation": info.ExplanationURL,
+		"checkAgain":  info.RetryAfter,
+	}).Info("Renewal info retrieved")
+
+	if time.Now().Before(renewAt) && !force {
+		logger.Info("Certificate is not due for renewal until ", renewAt)
+		return certID, false, nil
+	}
+	return certID, true, nil
+}
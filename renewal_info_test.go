@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestARICertID(t *testing.T) {
+	// the authority key identifier of the example in RFC 9773, section 4.1
+	aki := []byte{0x69, 0x88, 0x5B, 0x6B, 0x87, 0x46, 0x40, 0x41, 0xE1, 0xB3, 0x7B, 0x84, 0x7B, 0xA0, 0xAE, 0x2C, 0xDE, 0x01, 0xC8, 0xD4}
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		want    string
+		wantErr bool
+	}{
+		{
+			// RFC 9773, section 4.1: the serial needs a leading zero byte
+			"rfc9773 example",
+			&x509.Certificate{AuthorityKeyId: aki, SerialNumber: big.NewInt(0x87654321)},
+			"aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE",
+			false,
+		},
+		{
+			"serial without high bit",
+			&x509.Certificate{AuthorityKeyId: aki, SerialNumber: big.NewInt(0x12345678)},
+			"aYhba4dGQEHhs3uEe6CuLN4ByNQ.EjRWeA",
+			false,
+		},
+		{
+			"no authority key identifier",
+			&x509.Certificate{SerialNumber: big.NewInt(1)},
+			"",
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ariCertID(test.cert)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("certID = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRenewalTime(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(48 * time.Hour)
+
+	info := &renewalInfo{}
+	info.SuggestedWindow.Start = start
+	info.SuggestedWindow.End = end
+
+	for i := 0; i < 100; i++ {
+		if got := info.renewalTime(); got.Before(start) || !got.Before(end) {
+			t.Fatalf("renewal time %s is outside of the window [%s, %s)", got, start, end)
+		}
+	}
+}
+
+func TestGetRenewalInfo(t *testing.T) {
+	cert := &x509.Certificate{AuthorityKeyId: []byte{1, 2, 3, 4}, SerialNumber: big.NewInt(1)}
+	window := `{"suggestedWindow": {"start": "2026-10-01T00:00:00Z", "end": "2026-10-03T00:00:00Z"}}`
+
+	tests := []struct {
+		name         string
+		retryAfter   string
+		wantErr      bool
+		wantAttempts int
+	}{
+		{"available", "", false, 1},
+		{"retry soon", "0", false, 2},
+		// a far away Retry-After must not block the client for hours
+		{"retry much later", "86400", true, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if test.retryAfter != "" && attempts == 1 {
+					w.Header().Set("Retry-After", test.retryAfter)
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, window)
+			}))
+			defer server.Close()
+
+			acmeClient := &acmeClient{logger: discardLogger(), httpClient: server.Client()}
+			acmeClient.endpoints.RenewalInfo = server.URL + "/renewal-info"
+
+			info, err := acmeClient.getRenewalInfo(context.Background(), cert, 3)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if want := time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC); !info.SuggestedWindow.End.Equal(want) {
+				t.Errorf("window end = %s, want %s", info.SuggestedWindow.End, want)
+			}
+			if attempts != test.wantAttempts {
+				t.Errorf("sent %d requests, want %d", attempts, test.wantAttempts)
+			}
+		})
+	}
+}
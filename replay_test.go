@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/komplexon3/acme-client/acme_http"
+	"github.com/komplexon3/acme-client/cassette"
+	"github.com/komplexon3/acme-client/dns"
+
+	gin "github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// pebbleCassette holds an HTTP-01 issuance for example.com and www.example.com.
+// It is not a recording of a real Pebble: the responses were produced by a
+// hand-written stand-in that copies Pebble's URLs on localhost:14000 and its
+// alternate chain, so the nonces (pebble-nonce-N), IDs and certificates are
+// synthetic. The client wrote the cassette while talking to that stand-in.
+// To replace it with a real recording, run against Pebble
+//
+//	acme http01 --dir https://localhost:14000/dir --record 127.0.0.1 \
+//	  --domain example.com --domain www.example.com --agree-tos \
+//	  --dangerously-skip-tls-verify --no-verify-chain \
+//	  --cassette testdata/pebble-http01.json --preferred-chain "Pebble Root CA 1"
+//
+// The preferred chain makes the recording include the alternate chain.
+const pebbleCassette = "testdata/pebble-http01.json"
+
+func replayClient(t *testing.T, path string) *acmeClient {
+	t.Helper()
+	logger := logrus.New()
+	logger.Out = io.Discard
+	log := logrus.NewEntry(logger)
+	gin.SetMode(gin.TestMode)
+
+	client, err := setupClient(log, clientOptions{cassette: path, cassetteMode: cassette.Replay})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acmeClient := &acmeClient{
+		logger:                log,
+		agreeTOS:              true,
+		httpClient:            client,
+		replaying:             true,
+		dnsProvider:           dns.InitDNSProvider(log, net.ParseIP("127.0.0.1")),
+		httpChallengeProvider: acme_http.InitHTTPProvider(log),
+	}
+	acmeClient.nonces = newNoncePool(log, acmeClient.fetchNewNote, false)
+	return acmeClient
+}
+
+func TestReplayPebbleIssuance(t *testing.T) {
+	domains := []string{"example.com", "www.example.com"}
+	acmeClient := replayClient(t, pebbleCassette)
+
+	// the recording polled for several seconds, the replay must not
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	endpoints, err := getDirectory(ctx, *acmeClient.httpClient, "https://localhost:14000/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acmeClient.endpoints = *endpoints
+
+	acmeClient.privateKey, err = generateKey(P256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acmeClient.createAccount(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://localhost:14000/my-account/1"; acmeClient.accountURL != want {
+		t.Errorf("account URL = %s, want %s", acmeClient.accountURL, want)
+	}
+
+	order, err := acmeClient.createOrder(ctx, domains, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := acmeClient.solveAuthorizations(ctx, order, HTTP01, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Status != "valid" {
+			t.Errorf("authorization of %s is %s", result.Identifier, result.Status)
+		}
+	}
+
+	key, err := generateKey(P256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acmeClient.finalizeOrder(ctx, order, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := acmeClient.pollUntilReady(ctx, order, 25); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		preferredChain string
+		wantChains     int
+		wantIssuer     string
+	}{
+		{"default chain", "", 1, "Pebble Root CA 0"},
+		{"preferred alternate", "Pebble Root CA 1", 2, "Pebble Root CA 1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chains, err := acmeClient.getCertificateChains(ctx, order.certificateURL, test.preferredChain != "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chains) != test.wantChains {
+				t.Errorf("downloaded %d chains, want %d", len(chains), test.wantChains)
+			}
+			certs, err := parseChain(selectChain(acmeClient.logger, chains, test.preferredChain).certificate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(certs[0].DNSNames, domains) {
+				t.Errorf("certificate names = %v, want %v", certs[0].DNSNames, domains)
+			}
+			if issuer := certs[len(certs)-1].Issuer.CommonName; issuer != test.wantIssuer {
+				t.Errorf("chain issued by %s, want %s", issuer, test.wantIssuer)
+			}
+		})
+	}
+}
+
+func TestReplayBrokenAlternateChain(t *testing.T) {
+	data, err := os.ReadFile(pebbleCassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded cassette.Cassette
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		t.Fatal(err)
+	}
+	certificateURL := ""
+	for i, interaction := range recorded.Interactions {
+		if strings.HasSuffix(interaction.Request.URL, "/alternate/1") {
+			certificateURL = strings.TrimSuffix(interaction.Request.URL, "/alternate/1")
+			recorded.Interactions[i].Response = cassette.Response{
+				Status:  http.StatusNotFound,
+				Headers: http.Header{"Content-Type": {"application/problem+json"}},
+				Body:    `{"type": "urn:ietf:params:acme:error:malformed", "detail": "no such chain", "status": 404}`,
+			}
+		}
+	}
+	if certificateURL == "" {
+		t.Fatal("cassette has no alternate chain")
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	data, err = json.Marshal(&recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	acmeClient := replayClient(t, path)
+	acmeClient.endpoints.NewNonce = "https://localhost:14000/nonce-plz"
+	acmeClient.accountURL = "https://localhost:14000/my-account/1"
+	acmeClient.privateKey, err = generateKey(P256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the default chain is still usable if an alternate can't be downloaded
+	chains, err := acmeClient.getCertificateChains(context.Background(), certificateURL, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) != 1 {
+		t.Errorf("got %d chains, want only the default chain", len(chains))
+	}
+}
@@ -2,69 +2,41 @@ package main
 
 import (
 	"bytes"
-	"crypto/tls"
-	"crypto/x509"
+	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"net/url"
 
 	"github.com/komplexon3/acme-client/jose"
 )
 
-func setupClient(certFilePath string, proxy string) (*http.Client, error) {
-	rootCAs, _ := x509.SystemCertPool()
-	if rootCAs == nil {
-		rootCAs = x509.NewCertPool()
-	}
-
-	certs, err := ioutil.ReadFile(certFilePath)
-	if err != nil {
-		return nil, errors.New("Failed to append " + certFilePath + " to RootCAs: " + err.Error())
-	}
-
-	if ok := rootCAs.AppendCertsFromPEM(certs); !ok {
-		return nil, errors.New("Failed t append " + certFilePath + " to RootCAs")
-	}
-	// Trust the augmented cert pool in our client
-	config := &tls.Config{
-		InsecureSkipVerify: proxy != "",
-		RootCAs:            rootCAs,
-	}
-	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
-
-	if proxy != "" {
-		proxyURL, err := url.Parse(proxy)
-		if err != nil {
-			return nil, errors.New("Failed to parse proxy URL: " + err.Error())
-		}
-		client.Transport = &http.Transport{TLSClientConfig: config, Proxy: http.ProxyURL(proxyURL)}
-	}
-
-	return client, nil
+func (acme *acmeClient) doJosePostRequest(ctx context.Context, endpoint string, protected map[string]interface{}, payload interface{}) (*http.Response, error) {
+	return acme.doJosePostRequestAccept(ctx, endpoint, protected, payload, "")
 }
 
-func (acme *acmeClient) doJosePostRequest(endpoint string, protected map[string]interface{}, payload interface{}) (*http.Response, error) {
-	protected["nonce"] = acme.currentNonce
+// doJosePostRequestAccept sends a JWS signed request, retrying it where that is safe (see doWithRetry).
+// If accept is set, it is sent as Accept header.
+func (acme *acmeClient) doJosePostRequestAccept(ctx context.Context, endpoint string, protected map[string]interface{}, payload interface{}, accept string) (*http.Response, error) {
 	protected["url"] = endpoint
 
-	req, err := acme.josePostRequest(endpoint, protected, payload)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := acme.httpClient.Do(req)
-	if err == nil {
-		acme.currentNonce = resp.Header.Get("Replay-Nonce")
-	}
+	// a POST-as-GET only reads a resource, so it can be replayed like a GET
+	idempotent := payload == nil
 
-	return resp, err
+	return acme.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := acme.josePostRequest(ctx, endpoint, protected, payload)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return req, nil
+	}, idempotent)
 }
 
-func (acme *acmeClient) josePostRequest(endpoint string, protected map[string]interface{}, payload interface{}) (*http.Request, error) {
+func (acme *acmeClient) josePostRequest(ctx context.Context, endpoint string, protected map[string]interface{}, payload interface{}) (*http.Request, error) {
 
-	nonce, err := acme.Nonce()
+	nonce, err := acme.Nonce(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("Error getting nonce: %s", err)
 	}
@@ -73,12 +45,12 @@ func (acme *acmeClient) josePostRequest(endpoint string, protected map[string]in
 		Header:  protected,
 		Payload: payload,
 	}
-	signedBody, err := jwt.CreateSignedPayload(*acme.privateKey, nonce)
+	signedBody, err := jwt.CreateSignedPayload(acme.privateKey, nonce)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(signedBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(signedBody))
 	if err != nil {
 		return nil, errors.New("Failed to create JOSE POST request: " + err.Error())
 	}
@@ -88,17 +60,8 @@ func (acme *acmeClient) josePostRequest(endpoint string, protected map[string]in
 	return req, nil
 }
 
-func (acmeClient *acmeClient) Nonce() (string, error) {
-	if acmeClient.currentNonce != "" {
-		return acmeClient.currentNonce, nil
-	}
-
-	if err := acmeClient.fetchNewNote(); err != nil {
-		return "", err
-	}
-
-	nonce := acmeClient.currentNonce
-	acmeClient.currentNonce = ""
-
-	return nonce, nil
+// Nonce hands out a nonce from the pool. Each nonce is only valid once, so
+// a new one is fetched when there is none left.
+func (acmeClient *acmeClient) Nonce(ctx context.Context) (string, error) {
+	return acmeClient.nonces.Get(ctx)
 }
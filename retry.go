@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/komplexon3/acme-client/acme"
+)
+
+const (
+	// defaultMaxAttempts caps how often a single request is sent, including the first attempt
+	defaultMaxAttempts = 5
+	// baseBackoff is the delay before the first retry, it doubles with every attempt
+	baseBackoff = time.Second
+	// maxBackoff caps both the computed backoff and the Retry-After a server may ask for
+	maxBackoff = 30 * time.Second
+)
+
+// parseRetryAfter returns the point in time a Retry-After header refers to, or the zero time.
+// The header is either a number of seconds or an HTTP-date (RFC 9110, section 10.2.3).
+func parseRetryAfter(header string, now time.Time) time.Time {
+	if header == "" {
+		return time.Time{}
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return date
+	}
+	return time.Time{}
+}
+
+// backoff returns how long to wait before the given retry, using exponential backoff with full jitter
+func backoff(retry int) time.Duration {
+	ceiling := baseBackoff << retry
+	if ceiling <= 0 || ceiling > maxBackoff {
+		ceiling = maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// notSent reports whether the request failed before anything reached the server,
+// which makes it safe to send again even if it is not idempotent
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// transient reports whether a network error is worth retrying for idempotent requests
+func transient(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// doWithRetry sends the request built by newRequest until it succeeds, fails
+// for good or maxAttempts is reached. newRequest is called for every attempt
+// so that JWS requests are signed with a fresh nonce.
+//
+// badNonce is always retried, the server rejected the request before looking
+// at it. Everything else is only retried if it is safe: idempotent requests
+// (GET, HEAD and POST-as-GET) are retried on 429, 5xx and transient network
+// errors, other requests only if the server explicitly refused them with
+// rateLimited or the connection could not be established at all.
+//
+// The body of an error response is read and put back, so that callers can still parse it.
+func (acmeClient *acmeClient) doWithRetry(ctx context.Context, newRequest func() (*http.Request, error), idempotent bool) (*http.Response, error) {
+	logger := acmeClient.logger.WithField("method", "doWithRetry")
+
+	maxAttempts := acmeClient.maxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := acmeClient.httpClient.Do(req)
+		if err != nil {
+			if attempt < maxAttempts && (notSent(err) || (idempotent && transient(err))) {
+				wait := backoff(attempt - 1)
+				logger.WithError(err).Warnf("Request to %s failed, retrying in %s", req.URL, wait)
+				if err := acmeClient.wait(ctx, wait); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, err
+		}
+
+		// every response carries a fresh nonce, also error responses
+		nonce := resp.Header.Get("Replay-Nonce")
+		acmeClient.nonces.Put(nonce)
+
+		if resp.StatusCode < 400 {
+			return resp, nil
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		problem := acme.ParseProblem(resp, body)
+		if problem.Type == acme.BADNONCE {
+			// the pooled nonces are likely stale as well, only the one of this response is fresh
+			acmeClient.nonces.Clear()
+			acmeClient.nonces.Put(nonce)
+		}
+
+		if attempt >= maxAttempts {
+			return resp, nil
+		}
+
+		retryAt := parseRetryAfter(problem.RetryAfter, time.Now())
+
+		var wait time.Duration
+		switch {
+		case problem.Type == acme.BADNONCE:
+			// retry right away with the nonce of the error response
+			wait = 0
+		case problem.Type == acme.RATELIMITED, idempotent && (resp.StatusCode == 429 || resp.StatusCode >= 500):
+			wait = backoff(attempt - 1)
+			if !retryAt.IsZero() {
+				wait = time.Until(retryAt)
+			}
+		default:
+			return resp, nil
+		}
+
+		if wait > maxBackoff {
+			logger.Warnf("Server asked to retry %s in %s, giving up", req.URL, wait)
+			return resp, nil
+		}
+		if wait < 0 {
+			wait = 0
+		}
+
+		logger.WithField("problem", problem).Warnf("Request to %s failed, retrying in %s", req.URL, wait)
+		if err := acmeClient.wait(ctx, wait); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+}
+
+// wait pauses before the next retry or poll. A replayed cassette answers
+// right away, so nothing is gained by waiting for it.
+func (acmeClient *acmeClient) wait(ctx context.Context, d time.Duration) error {
+	if acmeClient.replaying {
+		return ctx.Err()
+	}
+	return sleepContext(ctx, d)
+}
+
+// sleepContext waits for d, or returns the error of ctx if it is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/komplexon3/acme-client/acme"
+	"github.com/sirupsen/logrus"
+)
+
+// discardLogger returns a logger for tests that nobody reads
+func discardLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.Out = io.Discard
+	return logrus.NewEntry(logger)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		header string
+		want   time.Time
+	}{
+		{"", time.Time{}},
+		{"0", now},
+		{"120", now.Add(2 * time.Minute)},
+		{"Thu, 01 Oct 2026 12:05:00 GMT", now.Add(5 * time.Minute)},
+		{"soon", time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			if got := parseRetryAfter(test.header, now); !got.Equal(test.want) {
+				t.Errorf("parseRetryAfter(%q) = %s, want %s", test.header, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for retry := 0; retry < 70; retry++ {
+		ceiling := baseBackoff << retry
+		if ceiling <= 0 || ceiling > maxBackoff {
+			ceiling = maxBackoff
+		}
+		for i := 0; i < 20; i++ {
+			if got := backoff(retry); got < 0 || got >= ceiling {
+				t.Fatalf("backoff(%d) = %s, want within [0, %s)", retry, got, ceiling)
+			}
+		}
+	}
+}
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryClassification(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantNotSent   bool
+		wantTransient bool
+	}{
+		{"dial refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true, true},
+		{"connection reset", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, false, true},
+		{"timeout", &net.OpError{Op: "read", Err: timeoutError{}}, false, true},
+		{"unexpected EOF", fmt.Errorf("reading response: %w", io.ErrUnexpectedEOF), false, true},
+		{"other", errors.New("certificate signed by unknown authority"), false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := notSent(test.err); got != test.wantNotSent {
+				t.Errorf("notSent = %t, want %t", got, test.wantNotSent)
+			}
+			if got := transient(test.err); got != test.wantTransient {
+				t.Errorf("transient = %t, want %t", got, test.wantTransient)
+			}
+		})
+	}
+}
+
+// reply is one canned response of the server in TestDoWithRetry
+type reply struct {
+	status  int
+	problem string
+}
+
+func TestDoWithRetry(t *testing.T) {
+	ok := reply{http.StatusOK, ""}
+	badNonce := reply{http.StatusBadRequest, acme.BADNONCE}
+	rateLimited := reply{http.StatusTooManyRequests, acme.RATELIMITED}
+	unavailable := reply{http.StatusServiceUnavailable, ""}
+	malformed := reply{http.StatusBadRequest, acme.MALFORMED}
+
+	tests := []struct {
+		name         string
+		replies      []reply
+		idempotent   bool
+		wantStatus   int
+		wantAttempts int
+	}{
+		{"success", []reply{ok}, false, http.StatusOK, 1},
+		{"bad nonce", []reply{badNonce, ok}, false, http.StatusOK, 2},
+		{"rate limited", []reply{rateLimited, ok}, false, http.StatusOK, 2},
+		{"unavailable, idempotent", []reply{unavailable, unavailable, ok}, true, http.StatusOK, 3},
+		{"unavailable, not idempotent", []reply{unavailable, ok}, false, http.StatusServiceUnavailable, 1},
+		{"malformed", []reply{malformed, ok}, true, http.StatusBadRequest, 1},
+		{"max attempts", []reply{badNonce, badNonce, badNonce, ok}, false, http.StatusBadRequest, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reply := test.replies[attempts]
+				attempts++
+				w.Header().Set("Replay-Nonce", fmt.Sprint("nonce-", attempts))
+				// retry right away instead of backing off
+				w.Header().Set("Retry-After", "0")
+				if reply.problem != "" {
+					w.Header().Set("Content-Type", "application/problem+json")
+				}
+				w.WriteHeader(reply.status)
+				if reply.problem != "" {
+					fmt.Fprintf(w, `{"type":%q,"detail":"test"}`, reply.problem)
+				}
+			}))
+			defer server.Close()
+
+			acmeClient := &acmeClient{
+				logger:      discardLogger(),
+				httpClient:  server.Client(),
+				maxAttempts: 3,
+			}
+			acmeClient.nonces = newNoncePool(acmeClient.logger, nil, false)
+
+			resp, err := acmeClient.doWithRetry(context.Background(), func() (*http.Request, error) {
+				return http.NewRequest(http.MethodPost, server.URL, nil)
+			}, test.idempotent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+			if attempts != test.wantAttempts {
+				t.Errorf("sent %d requests, want %d", attempts, test.wantAttempts)
+			}
+			// the nonce of the last response is pooled for the next request
+			if nonce, _ := acmeClient.nonces.take(); nonce != fmt.Sprint("nonce-", attempts) {
+				t.Errorf("pooled nonce = %s, want nonce-%d", nonce, attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+//go:build pkcs11
+
+package signer
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/ThalesIgnite/crypto11"
+)
+
+// OpenPKCS11 looks up the key pair labelled config.KeyLabel on the token and
+// returns a signer that performs all operations on the token.
+func OpenPKCS11(config PKCS11Config) (crypto.Signer, error) {
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path:       config.Module,
+		TokenLabel: config.TokenLabel,
+		Pin:        config.Pin,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error opening PKCS#11 token: %v", err)
+	}
+
+	key, err := ctx.FindKeyPair(nil, []byte(config.KeyLabel))
+	if err != nil {
+		return nil, fmt.Errorf("Error looking up key %s: %v", config.KeyLabel, err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("No key labelled %s on token %s", config.KeyLabel, config.TokenLabel)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,13 @@
+//go:build !pkcs11
+
+package signer
+
+import (
+	"crypto"
+	"errors"
+)
+
+// OpenPKCS11 is only available when building with -tags pkcs11, since it needs cgo
+func OpenPKCS11(config PKCS11Config) (crypto.Signer, error) {
+	return nil, errors.New("PKCS#11 support not compiled in, rebuild with -tags pkcs11")
+}
@@ -0,0 +1,23 @@
+package signer
+
+// The signer daemon speaks newline delimited JSON. Every request is answered
+// by exactly one response on the same connection.
+
+const (
+	opPublic = "public"
+	opSign   = "sign"
+)
+
+type request struct {
+	Op     string `json:"op"`
+	Digest []byte `json:"digest,omitempty"`
+	// Hash is the crypto.Hash the digest was computed with, 0 for Ed25519
+	Hash uint `json:"hash,omitempty"`
+}
+
+type response struct {
+	// PublicKey is the PKIX DER encoded public key
+	PublicKey []byte `json:"publicKey,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
@@ -0,0 +1,117 @@
+package signer
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds connecting to the signer daemon and each request to it.
+// crypto.Signer has no context, so a hung daemon is only noticed by the deadline.
+const DefaultTimeout = 30 * time.Second
+
+// Remote is a crypto.Signer whose private key is held by a signer daemon
+type Remote struct {
+	mu        sync.Mutex
+	conn      net.Conn
+	reader    *bufio.Reader
+	timeout   time.Duration
+	publicKey crypto.PublicKey
+}
+
+// Dial connects to a signer daemon and fetches its public key
+func Dial(network string, address string) (*Remote, error) {
+	return DialTimeout(network, address, DefaultTimeout)
+}
+
+// DialTimeout is like Dial, but gives up on the daemon if connecting or any
+// later request takes longer than timeout
+func DialTimeout(network string, address string, timeout time.Duration) (*Remote, error) {
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to signer: %v", err)
+	}
+
+	remote := &Remote{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}
+
+	resp, err := remote.call(request{Op: opPublic})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	remote.publicKey, err = x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Error parsing public key of signer: %v", err)
+	}
+
+	return remote, nil
+}
+
+func (remote *Remote) call(req request) (*response, error) {
+	remote.mu.Lock()
+	defer remote.mu.Unlock()
+
+	encoded, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := remote.conn.SetDeadline(time.Now().Add(remote.timeout)); err != nil {
+		return nil, err
+	}
+	// after a failed exchange a late response could be taken for the answer to
+	// the next request, so the connection is not used again
+	if _, err := remote.conn.Write(append(encoded, '\n')); err != nil {
+		remote.conn.Close()
+		return nil, fmt.Errorf("Error sending request to signer: %v", err)
+	}
+
+	line, err := remote.reader.ReadBytes('\n')
+	if err != nil {
+		remote.conn.Close()
+		if err == io.EOF {
+			return nil, errors.New("Signer closed the connection")
+		}
+		return nil, fmt.Errorf("Error reading response from signer: %v", err)
+	}
+
+	var resp response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling response from signer: %v", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("Signer returned error: %s", resp.Error)
+	}
+	return &resp, nil
+}
+
+func (remote *Remote) Public() crypto.PublicKey {
+	return remote.publicKey
+}
+
+func (remote *Remote) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	resp, err := remote.call(request{
+		Op:     opSign,
+		Digest: digest,
+		Hash:   uint(opts.HashFunc()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+func (remote *Remote) Close() error {
+	return remote.conn.Close()
+}
@@ -0,0 +1,117 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// listen opens a unix socket in a temporary directory
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "signer.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return listener
+}
+
+func TestRemoteSign(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := []byte("protected.payload")
+	digest := sha256.Sum256(message)
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		signed []byte
+		opts   crypto.SignerOpts
+		verify func(signature []byte) bool
+	}{
+		{"ecdsa", ecKey, digest[:], crypto.SHA256, func(signature []byte) bool {
+			return ecdsa.VerifyASN1(&ecKey.PublicKey, digest[:], signature)
+		}},
+		{"rsa", rsaKey, digest[:], crypto.SHA256, func(signature []byte) bool {
+			return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature) == nil
+		}},
+		// Ed25519 signs the message itself
+		{"ed25519", edKey, message, crypto.Hash(0), func(signature []byte) bool {
+			return ed25519.Verify(edKey.Public().(ed25519.PublicKey), message, signature)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logger := logrus.New()
+			logger.Out = io.Discard
+			listener := listen(t)
+			go Serve(listener, test.key, logrus.NewEntry(logger))
+
+			remote, err := Dial("unix", listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer remote.Close()
+
+			if !remote.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(test.key.Public()) {
+				t.Fatal("public key of the signer does not match")
+			}
+			signature, err := remote.Sign(rand.Reader, test.signed, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.verify(signature) {
+				t.Error("signature does not verify")
+			}
+		})
+	}
+}
+
+func TestRemoteTimeout(t *testing.T) {
+	// a daemon that accepts connections but never answers
+	listener := listen(t)
+	go func() {
+		var conns []net.Conn
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				for _, conn := range conns {
+					conn.Close()
+				}
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	start := time.Now()
+	if _, err := DialTimeout("unix", listener.Addr().String(), 100*time.Millisecond); err == nil {
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("gave up after %s", elapsed)
+	}
+}
@@ -0,0 +1,69 @@
+package signer
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Serve answers requests of Remote signers on listener using key until the listener is closed
+func Serve(listener net.Listener, key crypto.Signer, logger *logrus.Entry) error {
+	publicKey, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return err
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go handleConnection(conn, key, publicKey, logger)
+	}
+}
+
+func handleConnection(conn net.Conn, key crypto.Signer, publicKey []byte, logger *logrus.Entry) {
+	defer conn.Close()
+	logger.Info("Client connected")
+
+	reader := bufio.NewReader(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			logger.Info("Client disconnected")
+			return
+		}
+
+		var req request
+		var resp response
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = "malformed request"
+		} else {
+			switch req.Op {
+			case opPublic:
+				resp.PublicKey = publicKey
+			case opSign:
+				logger.WithField("hash", crypto.Hash(req.Hash)).Info("Signing digest")
+				resp.Signature, err = key.Sign(rand.Reader, req.Digest, crypto.Hash(req.Hash))
+				if err != nil {
+					logger.WithError(err).Error("Error signing digest")
+					resp.Error = err.Error()
+				}
+			default:
+				resp.Error = "unknown operation " + req.Op
+			}
+		}
+
+		// json.Encoder terminates every value with a newline
+		if err := encoder.Encode(resp); err != nil {
+			logger.WithError(err).Error("Error writing response")
+			return
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Package signer provides crypto.Signer implementations for keys that do not
+// live in the memory of the ACME client, e.g. keys on a PKCS#11 token or keys
+// held by a signer daemon listening on a unix socket.
+package signer
+
+import (
+	"crypto"
+	"fmt"
+	"strings"
+)
+
+// PKCS11Config describes where to find a key on a PKCS#11 token
+type PKCS11Config struct {
+	Module     string
+	TokenLabel string
+	Pin        string
+	KeyLabel   string
+}
+
+// Open returns the signer described by spec, which is either
+// "unix:<socket path>" or "pkcs11:<key label>". For PKCS#11 keys the token is
+// described by config, whose KeyLabel is overwritten by the label in spec.
+func Open(spec string, config PKCS11Config) (crypto.Signer, error) {
+	kind, value, found := strings.Cut(spec, ":")
+	if !found || value == "" {
+		return nil, fmt.Errorf("Invalid signer %q, expected unix:<socket> or pkcs11:<label>", spec)
+	}
+
+	switch kind {
+	case "unix":
+		return Dial("unix", value)
+	case "pkcs11":
+		config.KeyLabel = value
+		return OpenPKCS11(config)
+	default:
+		return nil, fmt.Errorf("Unsupported signer type %s", kind)
+	}
+}
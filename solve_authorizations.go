@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// authorizationResult is the outcome of solving the authorization of one identifier
+type authorizationResult struct {
+	Identifier string
+	Status     string
+	Err        error
+}
+
+// pendingChallenge is a challenge that is presented by one of our providers
+type pendingChallenge struct {
+	auth      *authorization
+	challenge *challenge
+	tripwire  chan bool
+}
+
+// name returns the identifier as it was ordered, i.e. with the wildcard label
+func (auth *authorization) name() string {
+	if auth.wildcard {
+		return "*." + auth.identifier.Value
+	}
+	return auth.identifier.Value
+}
+
+// forEach calls fn for every index below n, running at most concurrency calls at once
+func forEach(n int, concurrency int, fn func(i int)) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// solveAuthorizations fetches all authorizations of the order, presents their
+// challenges at once, waits until the CA looked at all of them and then polls
+// the authorizations. At most concurrency requests are in flight at a time.
+// It returns a result per identifier and an error if any of them failed.
+func (acme *acmeClient) solveAuthorizations(ctx context.Context, order *Order, mode ChallengeType, concurrency int) ([]authorizationResult, error) {
+	logger := acme.logger.WithField("method", "solveAuthorizations")
+
+	// fetch all authorizations
+	authorizations := make([]authorization, len(order.authorizations))
+	fetchErrors := make([]error, len(order.authorizations))
+	forEach(len(order.authorizations), concurrency, func(i int) {
+		auth, _, err := acme.getAuthorization(ctx, order.authorizations[i].authorizationURL)
+		if err != nil {
+			fetchErrors[i] = err
+			return
+		}
+		authorizations[i] = *auth
+	})
+	for i, err := range fetchErrors {
+		if err != nil {
+			logger.WithError(err).Error("Error getting authorization ", order.authorizations[i].authorizationURL)
+			return nil, fmt.Errorf("Error getting authorization %s: %v", order.authorizations[i].authorizationURL, err)
+		}
+	}
+	order.authorizations = authorizations
+
+	results := make([]authorizationResult, len(authorizations))
+	for i := range authorizations {
+		results[i] = authorizationResult{
+			Identifier: authorizations[i].name(),
+			Status:     authorizations[i].status,
+		}
+	}
+
+	// present the challenges of all pending authorizations, valid ones are reused as they are
+	pending := make([]*pendingChallenge, len(authorizations))
+	for i := range authorizations {
+		auth := &authorizations[i]
+		if auth.status != "pending" {
+			if auth.status != "valid" {
+				results[i].Err = auth.problem()
+			}
+			continue
+		}
+		chal, tripwire, err := acme.registerChallenge(auth, mode)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		logger.WithFields(logrus.Fields{
+			"identifier": auth.name(),
+			"challenge":  chal.Url,
+		}).Info("Challenge registered")
+		pending[i] = &pendingChallenge{auth: auth, challenge: chal, tripwire: tripwire}
+	}
+
+	// the challenges are only needed until the CA validated them
+	defer func() {
+		for _, p := range pending {
+			if p == nil {
+				continue
+			}
+			if err := acme.deregisterChallenge(p.auth, p.challenge); err != nil {
+				logger.WithError(err).Error("Error deregistering challenge ", p.challenge.Url)
+			}
+		}
+	}()
+
+	// tell the CA that the challenges are ready, wait until it looked at them and poll the result
+	forEach(len(pending), concurrency, func(i int) {
+		p := pending[i]
+		if p == nil {
+			return
+		}
+		if err := acme.respondToChallenge(ctx, p.challenge); err != nil {
+			results[i].Err = err
+		}
+	})
+	forEach(len(pending), len(pending), func(i int) {
+		p := pending[i]
+		if p == nil || results[i].Err != nil || acme.replaying {
+			return
+		}
+		select {
+		case <-p.tripwire:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+		}
+	})
+	forEach(len(pending), concurrency, func(i int) {
+		p := pending[i]
+		if p == nil || results[i].Err != nil {
+			return
+		}
+		results[i].Err = acme.pollAuthorization(ctx, p.auth, 25)
+		results[i].Status = p.auth.status
+	})
+
+	var failed []string
+	for _, result := range results {
+		entry := logger.WithFields(logrus.Fields{
+			"identifier": result.Identifier,
+			"status":     result.Status,
+		})
+		if result.Err != nil {
+			entry.WithError(result.Err).Error("Authorization failed")
+			failed = append(failed, result.Identifier+": "+result.Err.Error())
+		} else {
+			entry.Info("Authorization complete")
+		}
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("%d of %d authorizations failed: %s", len(failed), len(results), strings.Join(failed, "; "))
+	}
+	return results, nil
+}
@@ -0,0 +1,131 @@
+package state
+
+import (
+	"crypto"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/komplexon3/acme-client/keys"
+)
+
+type Account struct {
+	URL     string   `json:"url"`
+	Contact []string `json:"contact,omitempty"`
+	// Status is only set once the account was deactivated
+	Status string `json:"status,omitempty"`
+}
+
+func (ca *CA) accountDir(accountURL string) string {
+	return filepath.Join(ca.dir, "accounts", shortHash(accountURL))
+}
+
+// LoadAccount returns the current account of the CA and its key. Both are nil
+// if no account was saved yet, the key is nil if it is held by an external signer.
+func (ca *CA) LoadAccount() (*Account, crypto.Signer, error) {
+	if ca.info.CurrentAccount == "" {
+		return nil, nil, nil
+	}
+	dir := filepath.Join(ca.dir, "accounts", ca.info.CurrentAccount)
+
+	var account Account
+	found, err := readJSON(filepath.Join(dir, "account.json"), &account)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, nil
+	}
+
+	key, err := keys.Load(filepath.Join(dir, "account.key"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &account, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &account, key, nil
+}
+
+// AccountKeyPath returns the file holding the key of the current account, or an
+// empty string if there is no current account
+func (ca *CA) AccountKeyPath() string {
+	if ca.info.CurrentAccount == "" {
+		return ""
+	}
+	return filepath.Join(ca.dir, "accounts", ca.info.CurrentAccount, "account.key")
+}
+
+// Accounts lists all accounts saved for the CA
+func (ca *CA) Accounts() ([]Account, error) {
+	entries, err := os.ReadDir(filepath.Join(ca.dir, "accounts"))
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []Account
+	for _, entry := range entries {
+		var account Account
+		found, err := readJSON(filepath.Join(ca.dir, "accounts", entry.Name(), "account.json"), &account)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+// SaveAccount stores the account and, unless it is nil, its key, and makes it
+// the current account of the CA. Each file is replaced atomically so a crash
+// never leaves a key that does not match the server.
+func (ca *CA) SaveAccount(account *Account, key crypto.Signer) error {
+	dir := ca.accountDir(account.URL)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	if key != nil {
+		data, err := keys.MarshalPEM(key)
+		if err != nil {
+			return err
+		}
+		if err := keys.WriteFile(filepath.Join(dir, "account.key"), data); err != nil {
+			return err
+		}
+	}
+
+	if err := writeJSON(filepath.Join(dir, "account.json"), account); err != nil {
+		return err
+	}
+
+	ca.info.CurrentAccount = filepath.Base(dir)
+	return writeJSON(filepath.Join(ca.dir, "ca.json"), ca.info)
+}
+
+// DeactivateAccount marks the account as deactivated. If it is the current
+// account of the CA, the CA has no current account afterwards.
+func (ca *CA) DeactivateAccount(accountURL string) error {
+	dir := ca.accountDir(accountURL)
+
+	var account Account
+	found, err := readJSON(filepath.Join(dir, "account.json"), &account)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+	account.Status = "deactivated"
+	if err := writeJSON(filepath.Join(dir, "account.json"), account); err != nil {
+		return err
+	}
+
+	if ca.info.CurrentAccount != filepath.Base(dir) {
+		return nil
+	}
+	ca.info.CurrentAccount = ""
+	return writeJSON(filepath.Join(ca.dir, "ca.json"), ca.info)
+}
@@ -0,0 +1,119 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/komplexon3/acme-client/keys"
+)
+
+type Identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type Order struct {
+	URL            string       `json:"url"`
+	Account        string       `json:"account"`
+	Status         string       `json:"status"`
+	Identifiers    []Identifier `json:"identifiers"`
+	Authorizations []string     `json:"authorizations,omitempty"`
+	Finalize       string       `json:"finalize,omitempty"`
+	Certificate    string       `json:"certificate,omitempty"`
+	Updated        time.Time    `json:"updated"`
+}
+
+// SaveOrder stores the order, replacing an earlier version with the same URL
+func (ca *CA) SaveOrder(order *Order) error {
+	order.Updated = time.Now().UTC()
+	return writeJSON(filepath.Join(ca.dir, "orders", shortHash(order.URL)+".json"), order)
+}
+
+// Version is one certificate issued for a lineage
+type Version struct {
+	File        string    `json:"file"`
+	Certificate string    `json:"certificate"`
+	Order       string    `json:"order"`
+	Issued      time.Time `json:"issued"`
+}
+
+// Lineage groups all certificates issued for the same set of identifiers
+type Lineage struct {
+	Name        string       `json:"name"`
+	Identifiers []Identifier `json:"identifiers"`
+	Versions    []Version    `json:"versions"`
+
+	dir string
+}
+
+// LineageName returns the name of the lineage for the identifiers, which is
+// derived from the alphabetically first identifier and a hash of all of them
+func LineageName(identifiers []Identifier) string {
+	values := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		values[i] = identifier.Type + ":" + identifier.Value
+	}
+	sort.Strings(values)
+
+	// the server may return the identifiers in a different order than they were requested
+	first := ""
+	if len(values) > 0 {
+		_, value, _ := strings.Cut(values[0], ":")
+		first = strings.ReplaceAll(value, "*", "wildcard")
+	}
+	return sanitize(first) + "-" + shortHash(strings.Join(values, ","))
+}
+
+// Lineage returns the lineage for the identifiers, which has no versions if none were saved yet
+func (ca *CA) Lineage(identifiers []Identifier) (*Lineage, error) {
+	name := LineageName(identifiers)
+	lineage := &Lineage{
+		Name:        name,
+		Identifiers: identifiers,
+		dir:         filepath.Join(ca.dir, "certificates", name),
+	}
+	if _, err := readJSON(filepath.Join(lineage.dir, "lineage.json"), lineage); err != nil {
+		return nil, err
+	}
+	return lineage, nil
+}
+
+// Latest returns the most recently issued version, or nil if there is none
+func (lineage *Lineage) Latest() *Version {
+	if len(lineage.Versions) == 0 {
+		return nil
+	}
+	return &lineage.Versions[len(lineage.Versions)-1]
+}
+
+// Path returns the absolute path of the chain file of a version
+func (lineage *Lineage) Path(version *Version) string {
+	return filepath.Join(lineage.dir, version.File)
+}
+
+// SaveCertificate adds a new version with the given PEM chain to the lineage
+func (ca *CA) SaveCertificate(lineage *Lineage, chain []byte, certificateURL string, orderURL string) (*Version, error) {
+	if err := os.MkdirAll(lineage.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	version := Version{
+		File:        fmt.Sprintf("cert-%d.pem", len(lineage.Versions)+1),
+		Certificate: certificateURL,
+		Order:       orderURL,
+		Issued:      time.Now().UTC(),
+	}
+	if err := keys.WriteFile(filepath.Join(lineage.dir, version.File), chain); err != nil {
+		return nil, err
+	}
+
+	lineage.Versions = append(lineage.Versions, version)
+	if err := writeJSON(filepath.Join(lineage.dir, "lineage.json"), lineage); err != nil {
+		return nil, err
+	}
+	return &lineage.Versions[len(lineage.Versions)-1], nil
+}
@@ -0,0 +1,119 @@
+// Package state keeps accounts, orders and certificates between runs of the
+// client. Everything is grouped by the directory URL of the CA, so that the
+// same state directory can be used for several CAs.
+//
+// Layout of the state directory:
+//
+//	<ca>/ca.json                             directory URL and the current account
+//	<ca>/accounts/<account>/account.json     account URL and contacts
+//	<ca>/accounts/<account>/account.key      PKCS#8 PEM encoded account key
+//	<ca>/orders/<order>.json                 order URL, status and identifiers
+//	<ca>/certificates/<lineage>/lineage.json identifiers and issued versions
+//	<ca>/certificates/<lineage>/cert-N.pem   certificate chain of version N
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/komplexon3/acme-client/keys"
+)
+
+type Registry struct {
+	dir string
+}
+
+// CA holds the state belonging to one ACME directory URL
+type CA struct {
+	dir  string
+	info caInfo
+}
+
+type caInfo struct {
+	Directory      string `json:"directory"`
+	CurrentAccount string `json:"currentAccount,omitempty"`
+}
+
+// Open creates the state directory if needed
+func Open(dir string) (*Registry, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Registry{dir: dir}, nil
+}
+
+// CA returns the state of the CA with the given directory URL, creating it if needed
+func (registry *Registry) CA(directoryURL string) (*CA, error) {
+	ca := &CA{
+		dir:  filepath.Join(registry.dir, caName(directoryURL)),
+		info: caInfo{Directory: directoryURL},
+	}
+	for _, sub := range []string{"accounts", "orders", "certificates"} {
+		if err := os.MkdirAll(filepath.Join(ca.dir, sub), 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	found, err := readJSON(filepath.Join(ca.dir, "ca.json"), &ca.info)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if err := writeJSON(filepath.Join(ca.dir, "ca.json"), ca.info); err != nil {
+			return nil, err
+		}
+	}
+	return ca, nil
+}
+
+// caName turns a directory URL into a readable and unique directory name,
+// e.g. "localhost_14000_dir-929abca7" for https://localhost:14000/dir
+func caName(directoryURL string) string {
+	readable := directoryURL
+	if u, err := url.Parse(directoryURL); err == nil && u.Host != "" {
+		readable = u.Host + u.Path
+	}
+	return sanitize(readable) + "-" + shortHash(directoryURL)
+}
+
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.Trim(name, "/"))
+}
+
+func shortHash(value string) string {
+	digest := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(digest[:4])
+}
+
+func readJSON(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return keys.WriteFile(path, data)
+}
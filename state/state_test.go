@@ -0,0 +1,123 @@
+package state
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+const testDirectory = "https://localhost:14000/dir"
+
+func TestAccountPerCA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := registry.CA(testDirectory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.SaveAccount(&Account{URL: "https://localhost:14000/my-account/1"}, key); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen to make sure the account was written
+	ca, err = registry.CA(testDirectory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, loaded, err := ca.LoadAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account == nil || account.URL != "https://localhost:14000/my-account/1" {
+		t.Fatalf("account = %+v, want the saved account", account)
+	}
+	if loaded == nil || !key.Equal(loaded) {
+		t.Error("account key was not saved")
+	}
+
+	// an account never carries over to another CA
+	other, err := registry.CA("https://acme.example/directory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account, _, err := other.LoadAccount(); err != nil || account != nil {
+		t.Errorf("other CA account = %+v, %v, want none", account, err)
+	}
+}
+
+func TestDeactivateAccount(t *testing.T) {
+	registry, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := registry.CA(testDirectory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := &Account{URL: "https://localhost:14000/my-account/1"}
+	if err := ca.SaveAccount(account, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.DeactivateAccount(account.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen to make sure the change was written
+	ca, err = registry.CA(testDirectory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, _, err := ca.LoadAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != nil {
+		t.Errorf("deactivated account %s is still current", current.URL)
+	}
+	accounts, err := ca.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Status != "deactivated" {
+		t.Errorf("accounts = %+v, want one deactivated account", accounts)
+	}
+}
+
+func TestLineageName(t *testing.T) {
+	tests := []struct {
+		name        string
+		identifiers []Identifier
+		want        string
+	}{
+		{
+			"single",
+			[]Identifier{{"dns", "example.com"}},
+			"example.com-" + shortHash("dns:example.com"),
+		},
+		{
+			"wildcard",
+			[]Identifier{{"dns", "*.example.com"}},
+			"wildcard.example.com-" + shortHash("dns:*.example.com"),
+		},
+		{
+			"order does not matter",
+			[]Identifier{{"dns", "b.example.com"}, {"dns", "a.example.com"}},
+			"a.example.com-" + shortHash("dns:a.example.com,dns:b.example.com"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := LineageName(test.identifiers); got != test.want {
+				t.Errorf("LineageName = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
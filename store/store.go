@@ -2,6 +2,14 @@ package store
 
 import "github.com/sirupsen/logrus"
 
+// entry is one value of a key. The tripwire fires when the key is read.
+type entry struct {
+	val      string
+	tripwire chan bool
+}
+
+// Store maps keys to values. A key can hold several values, e.g. the TXT
+// records of a wildcard and a base domain share the same name.
 type Store struct {
 	setVal chan struct {
 		key      string
@@ -10,20 +18,19 @@ type Store struct {
 		tripwire chan bool
 	}
 	delVal chan struct {
-		key  string
-		resp chan error
+		key   string
+		value string
+		all   bool
+		resp  chan error
 	}
 	getVal chan struct {
 		key  string
-		resp chan string
+		resp chan []string
 	}
 }
 
 func RunStore(logger *logrus.Entry) *Store {
-	mapping := make(map[string]struct {
-		val      string
-		tripwire chan bool
-	})
+	mapping := make(map[string][]entry)
 
 	store := &Store{
 		setVal: make(chan struct {
@@ -33,12 +40,14 @@ func RunStore(logger *logrus.Entry) *Store {
 			tripwire chan bool
 		}),
 		delVal: make(chan struct {
-			key  string
-			resp chan error
+			key   string
+			value string
+			all   bool
+			resp  chan error
 		}),
 		getVal: make(chan struct {
 			key  string
-			resp chan string
+			resp chan []string
 		}),
 	}
 
@@ -47,27 +56,38 @@ func RunStore(logger *logrus.Entry) *Store {
 			select {
 			case add := <-store.setVal:
 				logger.Debugf("Adding key %s with value %s", add.key, add.value)
-				mapping[add.key] = struct {
-					val      string
-					tripwire chan bool
-				}{
-					val:      add.value,
-					tripwire: add.tripwire,
-				}
+				entries := removeValue(mapping[add.key], add.value)
+				mapping[add.key] = append(entries, entry{val: add.value, tripwire: add.tripwire})
 				add.err <- nil
 			case del := <-store.delVal:
-				logger.Debugf("Deleting key %s", del.key)
-				delete(mapping, del.key)
+				if del.all {
+					logger.Debugf("Deleting key %s", del.key)
+					delete(mapping, del.key)
+				} else {
+					logger.Debugf("Deleting value %s of key %s", del.value, del.key)
+					if entries := removeValue(mapping[del.key], del.value); len(entries) > 0 {
+						mapping[del.key] = entries
+					} else {
+						delete(mapping, del.key)
+					}
+				}
 				del.resp <- nil
 			case get := <-store.getVal:
-				logger.Debugf("Getting key %s -> value", get.key, mapping[get.key].val)
-				get.resp <- mapping[get.key].val
-				// signal that someone read this value
-				select {
-				case mapping[get.key].tripwire <- true:
-					// signal sent
-				default:
-					// no signal sent bc we already sent one and the channel is full
+				entries := mapping[get.key]
+				values := make([]string, len(entries))
+				for i, entry := range entries {
+					values[i] = entry.val
+				}
+				logger.Debugf("Getting key %s -> values %v", get.key, values)
+				get.resp <- values
+				// signal that someone read these values
+				for _, entry := range entries {
+					select {
+					case entry.tripwire <- true:
+						// signal sent
+					default:
+						// no signal sent bc we already sent one and the channel is full
+					}
 				}
 			}
 		}
@@ -76,9 +96,21 @@ func RunStore(logger *logrus.Entry) *Store {
 	return store
 }
 
+func removeValue(entries []entry, value string) []entry {
+	kept := entries[:0:0]
+	for _, entry := range entries {
+		if entry.val != value {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// Set adds value to key. The returned tripwire fires once the key has been read,
+// it is buffered so that a read is not missed if nobody is waiting yet.
 func (store *Store) Set(key string, value string) (chan bool, error) {
 	err := make(chan error)
-	tripwire := make(chan bool)
+	tripwire := make(chan bool, 1)
 	store.setVal <- struct {
 		key      string
 		value    string
@@ -88,20 +120,45 @@ func (store *Store) Set(key string, value string) (chan bool, error) {
 	return tripwire, <-err
 }
 
+// Del removes key with all its values
 func (store *Store) Del(key string) error {
 	resp := make(chan error)
 	store.delVal <- struct {
-		key  string
-		resp chan error
-	}{key, resp}
+		key   string
+		value string
+		all   bool
+		resp  chan error
+	}{key, "", true, resp}
 	return <-resp
 }
 
+// DelValue removes a single value of key and keeps the others
+func (store *Store) DelValue(key string, value string) error {
+	resp := make(chan error)
+	store.delVal <- struct {
+		key   string
+		value string
+		all   bool
+		resp  chan error
+	}{key, value, false, resp}
+	return <-resp
+}
+
+// Get returns the first value of key, or an empty string if there is none
 func (store *Store) Get(key string) string {
-	resp := make(chan string)
+	values := store.GetAll(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// GetAll returns all values of key
+func (store *Store) GetAll(key string) []string {
+	resp := make(chan []string)
 	store.getVal <- struct {
 		key  string
-		resp chan string
+		resp chan []string
 	}{key, resp}
 	return <-resp
 }
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestStore() *Store {
+	logger := logrus.New()
+	logger.Out = io.Discard
+	return RunStore(logrus.NewEntry(logger))
+}
+
+func TestStore(t *testing.T) {
+	type op struct {
+		action string
+		key    string
+		value  string
+	}
+	const name = "_acme-challenge.example.com."
+
+	tests := []struct {
+		name string
+		ops  []op
+		want []string
+	}{
+		{"single value", []op{{"set", name, "a"}}, []string{"a"}},
+		// a wildcard and its base domain share the TXT record name
+		{"two values", []op{{"set", name, "a"}, {"set", name, "b"}}, []string{"a", "b"}},
+		{"same value twice", []op{{"set", name, "a"}, {"set", name, "a"}}, []string{"a"}},
+		{"delete one value", []op{{"set", name, "a"}, {"set", name, "b"}, {"delvalue", name, "a"}}, []string{"b"}},
+		{"delete last value", []op{{"set", name, "a"}, {"delvalue", name, "a"}}, []string{}},
+		{"delete unknown value", []op{{"set", name, "a"}, {"delvalue", name, "c"}}, []string{"a"}},
+		{"delete key", []op{{"set", name, "a"}, {"set", name, "b"}, {"del", name, ""}}, []string{}},
+		{"other key", []op{{"set", "other.", "a"}}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTestStore()
+			for _, op := range test.ops {
+				var err error
+				switch op.action {
+				case "set":
+					_, err = store.Set(op.key, op.value)
+				case "del":
+					err = store.Del(op.key)
+				case "delvalue":
+					err = store.DelValue(op.key, op.value)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := store.GetAll(name); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("GetAll = %v, want %v", got, test.want)
+			}
+			want := ""
+			if len(test.want) > 0 {
+				want = test.want[0]
+			}
+			if got := store.Get(name); got != want {
+				t.Errorf("Get = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestStoreTripwire(t *testing.T) {
+	store := newTestStore()
+	first, err := store.Set("key", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Set("key", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-first:
+		t.Fatal("tripwire fired before the key was read")
+	default:
+	}
+
+	// nobody waits yet, the buffered tripwires must still remember the reads
+	store.GetAll("key")
+	store.GetAll("key")
+	for name, tripwire := range map[string]chan bool{"first": first, "second": second} {
+		select {
+		case <-tripwire:
+		default:
+			t.Errorf("%s tripwire did not fire", name)
+		}
+	}
+}
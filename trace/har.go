@@ -0,0 +1,185 @@
+package trace
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// The types below are the subset of HTTP Archive 1.2 that is needed to
+// describe an exchange. Fields starting with an underscore are custom fields
+// as allowed by the format, they hold the decoded JWS.
+
+type harLog struct {
+	Log harContent `json:"log"`
+}
+
+type harContent struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string                 `json:"method"`
+	URL         string                 `json:"url"`
+	HTTPVersion string                 `json:"httpVersion"`
+	Cookies     []harNameValue         `json:"cookies"`
+	Headers     []harNameValue         `json:"headers"`
+	QueryString []harNameValue         `json:"queryString"`
+	PostData    *harPostData           `json:"postData,omitempty"`
+	HeadersSize int                    `json:"headersSize"`
+	BodySize    int                    `json:"bodySize"`
+	Protected   map[string]interface{} `json:"_protected,omitempty"`
+	Payload     json.RawMessage        `json:"_payload,omitempty"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContentBody `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContentBody struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// writeHAR rewrites the whole archive, so that the file is complete even if the client exits early
+func (transport *Transport) writeHAR() error {
+	archive := harLog{Log: harContent{
+		Version: "1.2",
+		Creator: harCreator{Name: "acme-client", Version: "1"},
+		Entries: make([]harEntry, len(transport.entries)),
+	}}
+	for i, entry := range transport.entries {
+		archive.Log.Entries[i] = harEntryFor(entry)
+	}
+
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(transport.path), ".trace-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), transport.path)
+}
+
+func harEntryFor(entry *Entry) harEntry {
+	milliseconds := float64(entry.Duration) / float64(time.Millisecond)
+
+	request := harRequest{
+		Method:      entry.Method,
+		URL:         entry.URL,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(entry.RequestHeaders),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    0,
+		Protected:   entry.Protected,
+		Payload:     entry.Payload,
+	}
+	if entry.RequestBody != "" {
+		request.PostData = &harPostData{MimeType: entry.RequestHeaders.Get("Content-Type"), Text: entry.RequestBody}
+		request.BodySize = len(entry.RequestBody)
+	} else if entry.Protected != nil {
+		// the raw JWS is not kept, _protected and _payload hold what was signed
+		request.PostData = &harPostData{MimeType: entry.RequestHeaders.Get("Content-Type")}
+		request.BodySize = -1
+	}
+
+	response := harResponse{
+		Status:      entry.Status,
+		StatusText:  http.StatusText(entry.Status),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(entry.ResponseHeaders),
+		Content: harContentBody{
+			Size:     len(entry.ResponseBody),
+			MimeType: entry.ResponseHeaders.Get("Content-Type"),
+			Text:     entry.ResponseBody,
+			Encoding: entry.ResponseEncoding,
+		},
+		RedirectURL: entry.ResponseHeaders.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(entry.ResponseBody),
+	}
+
+	return harEntry{
+		StartedDateTime: entry.Started.Format(time.RFC3339Nano),
+		Time:            milliseconds,
+		Request:         request,
+		Response:        response,
+		Timings:         harTimings{Send: 0, Wait: milliseconds, Receive: 0},
+		Error:           entry.Error,
+	}
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	// map order is random, keep the archive stable
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].Name < headers[j].Name
+	})
+	return headers
+}
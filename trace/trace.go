@@ -0,0 +1,217 @@
+// Package trace records the HTTP exchanges with the ACME server to a file, so
+// that failed issuances can be inspected afterwards. JWS request bodies are
+// decoded into their protected header and payload, secrets are redacted.
+//
+// Two formats are supported:
+//
+//	jsonl  one Entry per line, appended as the exchanges happen
+//	har    an HTTP Archive 1.2, rewritten after every exchange
+package trace
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/komplexon3/acme-client/jose"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	JSONL = "jsonl"
+	HAR   = "har"
+
+	redacted = "REDACTED"
+)
+
+// redactedHeaders never end up in a trace
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Entry is one recorded exchange
+type Entry struct {
+	Started         time.Time              `json:"started"`
+	Duration        time.Duration          `json:"duration"`
+	Method          string                 `json:"method"`
+	URL             string                 `json:"url"`
+	RequestHeaders  http.Header            `json:"requestHeaders,omitempty"`
+	Protected       map[string]interface{} `json:"protected,omitempty"`
+	Payload         json.RawMessage        `json:"payload,omitempty"`
+	RequestBody     string                 `json:"requestBody,omitempty"`
+	Status          int                    `json:"status,omitempty"`
+	ResponseHeaders http.Header            `json:"responseHeaders,omitempty"`
+	ResponseBody    string                 `json:"responseBody,omitempty"`
+	// ResponseEncoding is base64 if the response body is not valid UTF-8
+	ResponseEncoding string `json:"responseEncoding,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// Transport is an http.RoundTripper that records every exchange of base
+type Transport struct {
+	base   http.RoundTripper
+	path   string
+	format string
+	logger *logrus.Entry
+
+	mu sync.Mutex
+	// entries are only kept for HAR, which is rewritten as a whole
+	entries []*Entry
+}
+
+// NewTransport records the exchanges of base to path in the given format.
+// An existing file is truncated. Errors writing the trace are logged to logger.
+func NewTransport(logger *logrus.Entry, base http.RoundTripper, path string, format string) (*Transport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	switch format {
+	case JSONL, HAR:
+	default:
+		return nil, fmt.Errorf("Unsupported trace format %s", format)
+	}
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		return nil, fmt.Errorf("Error creating trace file: %v", err)
+	}
+	return &Transport{
+		base:   base,
+		path:   path,
+		format: format,
+		logger: logger.WithField("component", "trace"),
+	}, nil
+}
+
+func (transport *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := &Entry{
+		Started:        time.Now(),
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestHeaders: redactHeaders(req.Header),
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		decodeRequestBody(entry, body)
+	}
+
+	resp, err := transport.base.RoundTrip(req)
+	entry.Duration = time.Since(entry.Started)
+	if err != nil {
+		entry.Error = err.Error()
+		transport.record(entry)
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	entry.Duration = time.Since(entry.Started)
+	entry.Status = resp.StatusCode
+	entry.ResponseHeaders = redactHeaders(resp.Header)
+	if utf8.Valid(body) {
+		entry.ResponseBody = string(body)
+	} else {
+		entry.ResponseBody = base64.StdEncoding.EncodeToString(body)
+		entry.ResponseEncoding = "base64"
+	}
+
+	transport.record(entry)
+	return resp, err
+}
+
+// record writes the entry, a trace that can't be written must not break issuance
+func (transport *Transport) record(entry *Entry) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	var err error
+	switch transport.format {
+	case JSONL:
+		err = transport.appendJSONL(entry)
+	case HAR:
+		transport.entries = append(transport.entries, entry)
+		err = transport.writeHAR()
+	}
+	if err != nil {
+		transport.logger.WithError(err).Warn("Error writing trace file")
+	}
+}
+
+func (transport *Transport) appendJSONL(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(transport.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// decodeRequestBody splits a JWS into its protected header and payload, other bodies are kept as they are
+func decodeRequestBody(entry *Entry, body []byte) {
+	jws, err := jose.Parse(body)
+	if err != nil {
+		entry.RequestBody = string(body)
+		return
+	}
+
+	entry.Protected = jws.Header
+	if jws.IsPostAsGet() {
+		return
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jws.Payload, &payload); err != nil {
+		// ACME payloads are objects, anything else is kept as a JSON string
+		entry.Payload, _ = json.Marshal(string(jws.Payload))
+		return
+	}
+	redactPayload(payload)
+	entry.Payload, _ = json.Marshal(payload)
+}
+
+// redactPayload removes the MAC of an external account binding, it proves
+// possession of the MAC key and is not needed to debug a request
+func redactPayload(payload map[string]interface{}) {
+	binding, ok := payload["externalAccountBinding"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	if _, ok := binding["signature"]; ok {
+		binding["signature"] = redacted
+	}
+}
+
+func redactHeaders(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	header = header.Clone()
+	for _, name := range redactedHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, redacted)
+		}
+	}
+	return header
+}
@@ -0,0 +1,80 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		format      string
+		keepEntries bool
+	}{
+		{JSONL, false},
+		{HAR, true},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "trace")
+			transport, err := NewTransport(logrus.NewEntry(logrus.New()), http.DefaultTransport, path, test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := &http.Client{Transport: transport}
+
+			for i := 0; i < 2; i++ {
+				req, _ := http.NewRequest(http.MethodHead, server.URL+"/new-nonce", nil)
+				req.Header.Set("Authorization", "secret")
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+
+			if kept := len(transport.entries) > 0; kept != test.keepEntries {
+				t.Errorf("entries kept in memory = %v, want %v", kept, test.keepEntries)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "secret") {
+				t.Error("Authorization header was not redacted")
+			}
+			if test.format != JSONL {
+				return
+			}
+			lines := 0
+			scanner := bufio.NewScanner(strings.NewReader(string(data)))
+			for scanner.Scan() {
+				var entry Entry
+				if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+					t.Fatal(err)
+				}
+				if entry.Status != http.StatusNoContent {
+					t.Errorf("status = %d, want %d", entry.Status, http.StatusNoContent)
+				}
+				lines++
+			}
+			if lines != 2 {
+				t.Errorf("%d lines, want 2", lines)
+			}
+		})
+	}
+}